@@ -4,6 +4,7 @@ package oauth2
 
 import (
 	"encoding"
+	"encoding/json"
 	"net/url"
 	"strconv"
 )
@@ -15,6 +16,10 @@ type AccessResponse struct {
 	ExpiresIn    int64
 	RefreshToken string
 	Info         map[string]interface{}
+
+	// AuthorizationDetails holds the granted authorization details.
+	// Services find the requested ones with AuthorizationDetailsFromContext.
+	AuthorizationDetails []AuthorizationDetail
 }
 
 // ToMap converts the access response to a map.
@@ -29,6 +34,10 @@ func (r *AccessResponse) ToMap() map[string]interface{} {
 		m["refresh_token"] = r.RefreshToken
 	}
 
+	if len(r.AuthorizationDetails) > 0 {
+		m["authorization_details"] = r.AuthorizationDetails
+	}
+
 	return m
 }
 
@@ -56,5 +65,12 @@ func (r *AccessResponse) ToValues() url.Values {
 	values.Set("token_type", r.TokenType)
 	values.Set("expires_in", strconv.FormatInt(r.ExpiresIn, 10))
 
+	if len(r.AuthorizationDetails) > 0 {
+		details, err := json.Marshal(r.AuthorizationDetails)
+		if err == nil {
+			values.Set("authorization_details", string(details))
+		}
+	}
+
 	return values
 }
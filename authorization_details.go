@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// AuthorizationDetail is a single entry of the authorization_details
+// request parameter:
+//
+// The request parameter "authorization_details" contains, in JSON
+// notation, an array of objects.  Each JSON object contains the data to
+// specify the authorization requirements for a certain type of
+// resource.  The type of resource or access requirement is determined
+// by the "type" field.
+//
+// https://tools.ietf.org/html/rfc9396#section-2
+type AuthorizationDetail map[string]interface{}
+
+// Type returns the type of the authorization detail.
+func (d AuthorizationDetail) Type() string {
+	typ, _ := d["type"].(string)
+	return typ
+}
+
+// AuthorizationDetailValidator validates authorization details of a
+// single type. It returns an error if the detail is invalid, unknown or
+// malformed.
+//
+// https://tools.ietf.org/html/rfc9396#section-5
+type AuthorizationDetailValidator interface {
+	ValidateAuthorizationDetail(ctx context.Context, client Client, detail AuthorizationDetail) error
+}
+
+// AuthorizationDetailsClient is a client restricted to a set of
+// authorization details types. Clients not implementing it may use any
+// registered type.
+//
+// https://tools.ietf.org/html/rfc9396#section-10
+type AuthorizationDetailsClient interface {
+	Client
+	IsAllowedAuthorizationDetailsType(typ string) bool
+}
+
+// AuthorizationDetailsFromContext returns the validated authorization
+// details of the request, if any.
+func AuthorizationDetailsFromContext(ctx context.Context) []AuthorizationDetail {
+	details, _ := ctx.Value(authorizationDetailsKey).([]AuthorizationDetail)
+	return details
+}
+
+func parseAuthorizationDetails(ctx context.Context, raw string, client Client, validators map[string]AuthorizationDetailValidator) ([]AuthorizationDetail, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var details []AuthorizationDetail
+	if err := json.Unmarshal([]byte(raw), &details); err != nil || len(details) == 0 {
+		return nil, ErrInvalidAuthorizationDetails
+	}
+
+	for _, detail := range details {
+		typ := detail.Type()
+		if typ == "" {
+			return nil, ErrInvalidAuthorizationDetails
+		}
+
+		validator, ok := validators[typ]
+		if !ok {
+			return nil, ErrInvalidAuthorizationDetails
+		}
+
+		if dc, ok := client.(AuthorizationDetailsClient); ok && !dc.IsAllowedAuthorizationDetailsType(typ) {
+			return nil, ErrInvalidAuthorizationDetails
+		}
+
+		if err := validator.ValidateAuthorizationDetail(ctx, client, detail); err != nil {
+			return nil, ErrInvalidAuthorizationDetails
+		}
+	}
+
+	return details, nil
+}
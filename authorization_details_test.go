@@ -0,0 +1,116 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testDetailValidator struct{}
+
+func (testDetailValidator) ValidateAuthorizationDetail(ctx context.Context, client Client, detail AuthorizationDetail) error {
+	if _, ok := detail["instructedAmount"]; !ok {
+		return errors.New("missing instructedAmount")
+	}
+	return nil
+}
+
+func TestParseAuthorizationDetails(t *testing.T) {
+	validators := map[string]AuthorizationDetailValidator{
+		"payment_initiation": testDetailValidator{},
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected int
+		err      error
+	}{
+		{"Empty", "", 0, nil},
+		{"Valid", `[{"type":"payment_initiation","instructedAmount":{"currency":"EUR","amount":"45.00"}}]`, 1, nil},
+		{"Malformed", `{"type":"payment_initiation"}`, 0, ErrInvalidAuthorizationDetails},
+		{"EmptyArray", `[]`, 0, ErrInvalidAuthorizationDetails},
+		{"MissingType", `[{"instructedAmount":{}}]`, 0, ErrInvalidAuthorizationDetails},
+		{"UnknownType", `[{"type":"account_information"}]`, 0, ErrInvalidAuthorizationDetails},
+		{"InvalidDetail", `[{"type":"payment_initiation"}]`, 0, ErrInvalidAuthorizationDetails},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseAuthorizationDetails(context.Background(), tt.raw, &testClient{id: "foo"}, validators)
+			if err != tt.err {
+				t.Errorf("parseAuthorizationDetails(%s) => error %v, expected %v", tt.raw, err, tt.err)
+			}
+			if len(got) != tt.expected {
+				t.Errorf("parseAuthorizationDetails(%s) => %d details, expected %d", tt.raw, len(got), tt.expected)
+			}
+		})
+	}
+}
+
+type testDetailsPasswordService struct{}
+
+func (testDetailsPasswordService) PasswordGrantTypeResponse(ctx context.Context, client Client, username, password string, issueRefreshToken bool) (*AccessResponse, error) {
+	return &AccessResponse{
+		AccessToken:          "token",
+		TokenType:            "bearer",
+		Info:                 map[string]interface{}{},
+		AuthorizationDetails: AuthorizationDetailsFromContext(ctx),
+	}, nil
+}
+
+func TestTokenAuthorizationDetails(t *testing.T) {
+	storer := testStorer{"foo": &testClient{id: "foo", secret: "bar", grantTypes: []string{PasswordGrantType}}}
+	h := NewHandler(storer, nil, NewPasswordGrantType(nil, testDetailsPasswordService{}))
+	h.AuthorizationDetailsTypes = map[string]AuthorizationDetailValidator{
+		"payment_initiation": testDetailValidator{},
+	}
+
+	tests := []struct {
+		details  string
+		status   int
+		expected string
+	}{
+		{`[{"type":"payment_initiation","instructedAmount":{"amount":"45.00","currency":"EUR"}}]`, http.StatusOK, `[{"instructedAmount":{"amount":"45.00","currency":"EUR"},"type":"payment_initiation"}]`},
+		{`[{"type":"account_information"}]`, http.StatusBadRequest, `"invalid_authorization_details"`},
+		{"", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.details, func(t *testing.T) {
+			t.Parallel()
+
+			form := url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"secret"}}
+			if tt.details != "" {
+				form.Set("authorization_details", tt.details)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("foo", "bar")
+			w := httptest.NewRecorder()
+			h.Token(w, req)
+
+			var got map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != tt.status {
+				t.Fatalf("Token(%s) => %d %s, expected %d", tt.details, w.Code, w.Body.String(), tt.status)
+			}
+			actual := got["authorization_details"]
+			if tt.status != http.StatusOK {
+				actual = got["error"]
+			}
+			if string(actual) != tt.expected {
+				t.Errorf("Token(%s) => %s, expected %s", tt.details, actual, tt.expected)
+			}
+		})
+	}
+}
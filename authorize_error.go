@@ -0,0 +1,81 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+)
+
+// AuthorizeErrorPage is the data the authorize error template is
+// executed with.
+type AuthorizeErrorPage struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Error is the error code.
+	Error string
+	// Description is the human-readable description of the error, if any.
+	Description string
+	// URI identifies a page with information about the error, if any.
+	URI string
+}
+
+// DefaultAuthorizeErrorTemplate renders the error code and description.
+var DefaultAuthorizeErrorTemplate = template.Must(template.New("authorize_error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Authorization failed</title></head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.Error}}{{if .Description}}: {{.Description}}{{end}}</p>
+{{- if .URI}}
+<p><a href="{{.URI}}">More information</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// refusePage renders the authorize error template instead of
+// redirecting to the client. It is used if the client or the redirection
+// URI is missing or invalid, as the user-agent must not be redirected to
+// an unverified redirection URI.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.1.2.1
+func (h *Handler) refusePage(w http.ResponseWriter, req *http.Request, status int, err error) {
+	emitRefused(req.Context(), errorCode(err))
+
+	page := &AuthorizeErrorPage{Status: status, Error: errorText(status, err)}
+
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Status != 0 {
+			page.Status = e.Status
+		}
+		for k := range e.Header {
+			w.Header()[k] = e.Header[k]
+		}
+		if e.Cause != nil {
+			h.logger.Println(e.Cause)
+		}
+		page.Description = e.Description
+		page.URI = e.URI
+	} else if isServerError(status) {
+		h.logger.Println(err)
+	}
+
+	tmpl := h.AuthorizeErrorTemplate
+	if tmpl == nil {
+		tmpl = DefaultAuthorizeErrorTemplate
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Pragma", "no-cache")
+
+	w.WriteHeader(page.Status)
+
+	if err := tmpl.Execute(w, page); err != nil {
+		h.logger.Println(err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testPolicyClient struct {
+	*testClient
+}
+
+func (c testPolicyClient) RegisteredRedirectURIs() []string {
+	return c.redirectURIs
+}
+
+func (c testPolicyClient) RequiresState() bool {
+	return false
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	storer := testStorer{
+		"foo": &testClient{
+			id:           "foo",
+			redirectURIs: []string{"https://client.example.com/cb"},
+			grantTypes:   []string{PasswordGrantType},
+		},
+		"baz": testPolicyClient{&testClient{
+			id:           "baz",
+			redirectURIs: []string{"https://client.example.com/cb"},
+			grantTypes:   []string{PasswordGrantType},
+		}},
+	}
+	h := NewHandler(storer, nil, NewImplicitGrantType(nil, nil))
+
+	tests := []struct {
+		query    string
+		status   int
+		page     string
+		location string
+	}{
+		{"response_type=token&redirect_uri=https://client.example.com/cb&state=xyz", http.StatusBadRequest, "client_id parameter is missing", ""},
+		{"response_type=token&client_id=bar&redirect_uri=https://client.example.com/cb&state=xyz", http.StatusUnauthorized, "invalid_client", ""},
+		{"response_type=token&client_id=foo&state=xyz", http.StatusBadRequest, "redirect_uri parameter is missing", ""},
+		{"response_type=token&client_id=foo&redirect_uri=https://attacker.example.com/cb&state=xyz", http.StatusBadRequest, "does not match", ""},
+		{"response_type=code&client_id=foo&redirect_uri=https://client.example.com/cb&state=xyz", http.StatusFound, "", "https://client.example.com/cb?error=unsupported_response_type&state=xyz"},
+		{"client_id=foo&redirect_uri=https://client.example.com/cb&state=xyz", http.StatusFound, "", "https://client.example.com/cb?error=invalid_request"},
+		{"response_type=token&client_id=foo&redirect_uri=https://client.example.com/cb&state=xyz", http.StatusFound, "", "https://client.example.com/cb#error=unauthorized_client&state=xyz"},
+		{"response_type=token&client_id=foo&redirect_uri=https://client.example.com/cb", http.StatusFound, "", "https://client.example.com/cb#error=invalid_request"},
+		{"response_type=token&client_id=baz", http.StatusFound, "", "https://client.example.com/cb#error=unauthorized_client"},
+		{"response_type=token&client_id=baz&redirect_uri=https://attacker.example.com/cb", http.StatusBadRequest, "does not match", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			h.Authorize(w, httptest.NewRequest(http.MethodGet, "/authorize?"+tt.query, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("Authorize(%s) => %d, expected %d", tt.query, w.Code, tt.status)
+			}
+			if tt.page != "" && (!strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), tt.page)) {
+				t.Errorf("Authorize(%s) => %s, expected page with %s", tt.query, w.Body.String(), tt.page)
+			}
+			if location := w.Header().Get("Location"); !strings.HasPrefix(location, tt.location) {
+				t.Errorf("Authorize(%s) => %s, expected %s", tt.query, location, tt.location)
+			}
+		})
+	}
+}
+
+type testImplicitService struct{}
+
+func (testImplicitService) ImplicitGrantTypeResponse(w http.ResponseWriter, req *http.Request, client Client, params url.Values) (*AccessResponse, error) {
+	return &AccessResponse{AccessToken: "token", TokenType: "bearer", ExpiresIn: 3600, Info: map[string]interface{}{}}, nil
+}
+
+func TestAuthorizeStatePolicy(t *testing.T) {
+	storer := testStorer{
+		"foo": &testClient{
+			id:           "foo",
+			redirectURIs: []string{"https://client.example.com/cb"},
+			grantTypes:   []string{ImplicitGrantType},
+		},
+		"baz": testPolicyClient{&testClient{
+			id:           "baz",
+			redirectURIs: []string{"https://client.example.com/cb"},
+			grantTypes:   []string{ImplicitGrantType},
+		}},
+	}
+	h := NewHandler(storer, nil, NewImplicitGrantType(nil, testImplicitService{}))
+
+	tests := []struct {
+		query    string
+		location string
+	}{
+		{"response_type=token&client_id=foo&redirect_uri=https://client.example.com/cb&state=xyz", "https://client.example.com/cb#access_token=token&expires_in=3600&state=xyz&token_type=bearer"},
+		{"response_type=token&client_id=baz&redirect_uri=https://client.example.com/cb", "https://client.example.com/cb#access_token=token&expires_in=3600&token_type=bearer"},
+		{"response_type=token&client_id=baz", "https://client.example.com/cb#access_token=token&expires_in=3600&token_type=bearer"},
+		{"response_type=token&client_id=baz&state=xyz", "https://client.example.com/cb#access_token=token&expires_in=3600&state=xyz&token_type=bearer"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			h.Authorize(w, httptest.NewRequest(http.MethodGet, "/authorize?"+tt.query, nil))
+
+			if location := w.Header().Get("Location"); w.Code != http.StatusFound || location != tt.location {
+				t.Errorf("Authorize(%s) => %d %s, expected %s", tt.query, w.Code, location, tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Consent is the authorization a resource owner granted a client for a
+// set of scopes.
+type Consent struct {
+	Subject   string    `json:"subject"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// ConsentStore stores the consents granted by resource owners.
+type ConsentStore interface {
+	// FindConsent returns the consent of the subject for the client,
+	// or nil if there is none.
+	FindConsent(ctx context.Context, subject, clientID string) (*Consent, error)
+	// SaveConsent creates or replaces the consent of the subject for the client.
+	SaveConsent(ctx context.Context, consent *Consent) error
+	// ListConsents returns all consents of the subject.
+	ListConsents(ctx context.Context, subject string) ([]*Consent, error)
+	// RevokeConsent deletes the consent of the subject for the client.
+	RevokeConsent(ctx context.Context, subject, clientID string) error
+}
+
+// ConsentPrompter obtains consent from the resource owner.
+type ConsentPrompter interface {
+	// PromptConsent asks the resource owner to grant the requested scopes
+	// to the client. It either renders a consent page, in which case it
+	// returns decided false, or processes the resource owner's decision
+	// and returns the granted scopes. It returns ErrAccessDenied if the
+	// resource owner denied the request.
+	PromptConsent(w http.ResponseWriter, req *http.Request, client Client, reqParams url.Values, scopes []string) (granted []string, decided bool, err error)
+}
+
+var errConsentConfig = errors.New("oauth2: consent requires ConsentStore, ConsentPrompter and Authenticator")
+
+// GrantedScopesFromContext returns the scopes the resource owner
+// consented to, if consent is managed by the handler.
+func GrantedScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(grantedScopesKey).([]string)
+	return scopes
+}
+
+// consent ensures the resource owner consented to the requested scopes,
+// prompting if needed. It returns false if a response has been written.
+func (h *Handler) consent(w http.ResponseWriter, req *http.Request, reqParams url.Values, client Client, redirectURI string, scopes []string, prompt []string) ([]string, bool) {
+	state := reqParams.Get("state")
+
+	session := SessionFromContext(req.Context())
+	if session == nil {
+		redirectWithError(w, req, redirectURI, state, ErrLoginRequired)
+		return nil, false
+	}
+	subject := session.Subject
+
+	existing, err := h.ConsentStore.FindConsent(req.Context(), subject, client.Identifier())
+	if err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil, false
+	}
+
+	if existing != nil && !containsString(prompt, "consent") && coversScopes(existing.Scopes, scopes) {
+		return scopes, true
+	}
+
+	if containsString(prompt, "none") {
+		redirectWithError(w, req, redirectURI, state, ErrConsentRequired)
+		return nil, false
+	}
+
+	granted, decided, err := h.ConsentPrompter.PromptConsent(w, req, client, reqParams, scopes)
+	if errors.Is(err, ErrAccessDenied) {
+		redirectWithError(w, req, redirectURI, state, err)
+		return nil, false
+	} else if err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil, false
+	}
+	if !decided {
+		return nil, false
+	}
+
+	granted = intersectScopes(granted, scopes)
+
+	consent := &Consent{
+		Subject:   subject,
+		ClientID:  client.Identifier(),
+		Scopes:    granted,
+		GrantedAt: time.Now(),
+	}
+	if existing != nil {
+		consent.Scopes = unionScopes(existing.Scopes, granted)
+	}
+
+	if err := h.ConsentStore.SaveConsent(req.Context(), consent); err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil, false
+	}
+
+	return intersectScopes(scopes, consent.Scopes), true
+}
+
+// Consents lets the authenticated resource owner manage the consents
+// granted to clients. GET lists them, DELETE with a client_id revokes
+// the consent granted to that client. It responds with 404 Not Found if
+// the handler has no ConsentStore or Authenticator.
+func (h *Handler) Consents(w http.ResponseWriter, req *http.Request) {
+	if h.ConsentStore == nil || h.Authenticator == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	session, err := h.Authenticator.Session(req)
+	if err != nil {
+		writeError(w, h.logger, http.StatusInternalServerError, err, "")
+		return
+	}
+	if session == nil {
+		writeError(w, h.logger, http.StatusUnauthorized, ErrLoginRequired, "")
+		return
+	}
+	subject := session.Subject
+
+	switch req.Method {
+	case http.MethodGet:
+		consents, err := h.ConsentStore.ListConsents(req.Context(), subject)
+		if err != nil {
+			writeError(w, h.logger, http.StatusInternalServerError, err, "")
+			return
+		}
+		if consents == nil {
+			consents = []*Consent{}
+		}
+		writeJSON(w, h.logger, http.StatusOK, consents, nil)
+	case http.MethodDelete:
+		clientID := req.FormValue("client_id")
+		if clientID == "" {
+			writeError(w, h.logger, http.StatusBadRequest, ErrInvalidRequest, "")
+			return
+		}
+		if err := h.ConsentStore.RevokeConsent(req.Context(), subject, clientID); err != nil {
+			writeError(w, h.logger, http.StatusInternalServerError, err, "")
+			return
+		}
+		writeJSON(w, h.logger, http.StatusNoContent, nil, nil)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeError(w, h.logger, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+	}
+}
+
+func parseScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func coversScopes(granted, requested []string) bool {
+	for _, scope := range requested {
+		if !containsString(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func intersectScopes(a, b []string) []string {
+	scopes := make([]string, 0, len(a))
+	for _, scope := range a {
+		if containsString(b, scope) && !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func unionScopes(a, b []string) []string {
+	scopes := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, scope := range list {
+			if !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
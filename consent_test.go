@@ -0,0 +1,140 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testConsentStore map[string]*Consent
+
+func (s testConsentStore) FindConsent(ctx context.Context, subject, clientID string) (*Consent, error) {
+	return s[subject+" "+clientID], nil
+}
+
+func (s testConsentStore) SaveConsent(ctx context.Context, consent *Consent) error {
+	s[consent.Subject+" "+consent.ClientID] = consent
+	return nil
+}
+
+func (s testConsentStore) ListConsents(ctx context.Context, subject string) ([]*Consent, error) {
+	var consents []*Consent
+	for _, consent := range s {
+		if consent.Subject == subject {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (s testConsentStore) RevokeConsent(ctx context.Context, subject, clientID string) error {
+	delete(s, subject+" "+clientID)
+	return nil
+}
+
+type testConsentPrompter struct {
+	prompted bool
+	grant    []string
+}
+
+func (p *testConsentPrompter) PromptConsent(w http.ResponseWriter, req *http.Request, client Client, reqParams url.Values, scopes []string) ([]string, bool, error) {
+	p.prompted = true
+	return p.grant, true, nil
+}
+
+func TestConsent(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		scopes   []string
+		prompt   []string
+		grant    []string
+		prompted bool
+		expected []string
+		stored   []string
+		location string
+	}{
+		{"New", nil, []string{"read"}, nil, []string{"read", "admin"}, true, []string{"read"}, []string{"read"}, ""},
+		{"Existing", []string{"read", "write"}, []string{"read"}, nil, nil, false, []string{"read"}, []string{"read", "write"}, ""},
+		{"NewScope", []string{"read"}, []string{"read", "write"}, nil, []string{"write"}, true, []string{"read", "write"}, []string{"read", "write"}, ""},
+		{"PromptConsent", []string{"read"}, []string{"read"}, []string{"consent"}, []string{"read"}, true, []string{"read"}, []string{"read"}, ""},
+		{"PromptNone", nil, []string{"read"}, []string{"none"}, nil, false, nil, nil, "error=consent_required"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := testConsentStore{}
+			if tt.existing != nil {
+				store["alice foo"] = &Consent{Subject: "alice", ClientID: "foo", Scopes: tt.existing}
+			}
+			prompter := &testConsentPrompter{grant: tt.grant}
+			h := NewHandler(nil, nil)
+			h.ConsentStore = store
+			h.ConsentPrompter = prompter
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+			req = withContextValue(req, sessionKey, &Session{Subject: "alice"})
+			params := url.Values{"redirect_uri": {"https://client.example.com/cb"}, "state": {"xyz"}}
+
+			got, ok := h.consent(w, req, params, &testClient{id: "foo"}, params.Get("redirect_uri"), tt.scopes, tt.prompt)
+			if prompter.prompted != tt.prompted {
+				t.Errorf("consent prompted => %t, expected %t", prompter.prompted, tt.prompted)
+			}
+			if ok != (tt.location == "") || !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("consent => %v, %t, expected %v", got, ok, tt.expected)
+			}
+			if tt.stored != nil && !reflect.DeepEqual(store["alice foo"].Scopes, tt.stored) {
+				t.Errorf("consent stored => %v, expected %v", store["alice foo"].Scopes, tt.stored)
+			}
+			if location := w.Header().Get("Location"); !strings.Contains(location, tt.location) {
+				t.Errorf("consent redirected => %s, expected %s", location, tt.location)
+			}
+		})
+	}
+}
+
+func TestConsentRequiresConfiguration(t *testing.T) {
+	storer := testStorer{"foo": &testClient{
+		id:           "foo",
+		redirectURIs: []string{"https://client.example.com/cb"},
+		grantTypes:   []string{ImplicitGrantType},
+	}}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		store         ConsentStore
+		prompter      ConsentPrompter
+	}{
+		{"WithoutAuthenticator", nil, testConsentStore{}, &testConsentPrompter{}},
+		{"WithoutPrompter", &testAuthenticator{&Session{Subject: "alice"}}, testConsentStore{}, nil},
+		{"WithoutStore", &testAuthenticator{&Session{Subject: "alice"}}, nil, &testConsentPrompter{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := NewHandler(storer, nil, NewImplicitGrantType(nil, nil))
+			h.Authenticator = tt.authenticator
+			h.ConsentStore = tt.store
+			h.ConsentPrompter = tt.prompter
+
+			w := httptest.NewRecorder()
+			h.Authorize(w, httptest.NewRequest(http.MethodGet, "/authorize?response_type=token&client_id=foo&redirect_uri=https://client.example.com/cb&state=xyz", nil))
+
+			if location := w.Header().Get("Location"); !strings.Contains(location, "error=server_error") {
+				t.Errorf("Authorize redirected => %s, expected server_error", location)
+			}
+		})
+	}
+}
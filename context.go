@@ -0,0 +1,24 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey int
+
+const (
+	authorizationDetailsKey contextKey = iota
+	resourcesKey
+	responderKey
+	grantedScopesKey
+	sessionKey
+	clientSecretKey
+	eventsKey
+)
+
+func withContextValue(req *http.Request, key contextKey, value interface{}) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), key, value))
+}
@@ -2,7 +2,10 @@
 
 package oauth2
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
 
 // ErrInvalidRequest is returned when:
 //
@@ -92,3 +95,222 @@ var ErrUnsupportedResponseType = errors.New("unsupported_response_type")
 // https://tools.ietf.org/html/rfc6749#section-4.1.2.1
 // https://tools.ietf.org/html/rfc6749#section-4.2.2.1
 var ErrServerError = errors.New("server_error")
+
+// ErrTemporarilyUnavailable is returned when:
+//
+// The authorization server is currently unable to handle
+// the request due to a temporary overloading or maintenance
+// of the server.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.1.2.1
+var ErrTemporarilyUnavailable = errors.New("temporarily_unavailable")
+
+// ErrInvalidAuthorizationDetails is returned when:
+//
+// The authorization details contained in the request are not valid:
+// the parameter is malformed, contains an unknown authorization details
+// type, or a detail is not valid for its type.
+//
+// https://tools.ietf.org/html/rfc9396#section-5
+var ErrInvalidAuthorizationDetails = errors.New("invalid_authorization_details")
+
+// ErrInvalidTarget is returned when:
+//
+// The requested resource is invalid, missing, unknown, or malformed.
+//
+// https://tools.ietf.org/html/rfc8707#section-2
+var ErrInvalidTarget = errors.New("invalid_target")
+
+// ErrLoginRequired is returned when:
+//
+// The Authorization Server requires End-User authentication.  This
+// error MAY be returned when the prompt parameter value in the
+// Authentication Request is none, but the Authentication Request cannot
+// be completed without displaying a user interface for End-User
+// authentication.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+var ErrLoginRequired = errors.New("login_required")
+
+// ErrConsentRequired is returned when:
+//
+// The Authorization Server requires End-User consent.  This error MAY
+// be returned when the prompt parameter value in the Authentication
+// Request is none, but the Authentication Request cannot be completed
+// without displaying a user interface for End-User consent.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+var ErrConsentRequired = errors.New("consent_required")
+
+// ErrAuthorizationPending is returned when:
+//
+// The authorization request is still pending as the end-user hasn't
+// yet been authenticated.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+var ErrAuthorizationPending = errors.New("authorization_pending")
+
+// ErrSlowDown is returned when:
+//
+// A variant of "authorization_pending", the authorization request is
+// still pending and polling should continue, but the interval MUST be
+// increased by at least 5 seconds for this and all subsequent requests.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+var ErrSlowDown = errors.New("slow_down")
+
+// ErrExpiredToken is returned when:
+//
+// The auth_req_id has expired.  The Client will need to make a new
+// Authentication Request.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+var ErrExpiredToken = errors.New("expired_token")
+
+// ErrUnknownUserID is returned when:
+//
+// The OpenID Provider is not able to identify which end-user the Client
+// wishes to be authenticated by means of the hint provided in the
+// request (login_hint_token, id_token_hint, or login_hint).
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+var ErrUnknownUserID = errors.New("unknown_user_id")
+
+// ErrExpiredLoginHintToken is returned when:
+//
+// The login_hint_token provided in the authentication request is not
+// valid because it has expired.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+var ErrExpiredLoginHintToken = errors.New("expired_login_hint_token")
+
+// ErrInvalidBindingMessage is returned when:
+//
+// The binding message is invalid or unacceptable for use in the
+// context of the given request.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+var ErrInvalidBindingMessage = errors.New("invalid_binding_message")
+
+// ErrMissingUserCode is returned when:
+//
+// User code is required but was missing from the request.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+var ErrMissingUserCode = errors.New("missing_user_code")
+
+// ErrInvalidUserCode is returned when:
+//
+// User code was invalid.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.13
+var ErrInvalidUserCode = errors.New("invalid_user_code")
+
+// Error is an error response with details. Services return it to pass
+// an error description, URI, HTTP status or headers on to the client.
+// It matches the sentinel error of its code with errors.Is:
+//
+//	return nil, &oauth2.Error{
+//		Code:        oauth2.ErrInvalidGrant.Error(),
+//		Description: "The authorization code has expired.",
+//		Cause:       err,
+//	}
+//
+// https://tools.ietf.org/html/rfc6749#section-5.2
+type Error struct {
+	// Code is the error code, one of the sentinel errors or an
+	// extension error code.
+	Code string
+	// Description is a human-readable text providing additional
+	// information to the client developer.
+	Description string
+	// URI identifies a human-readable web page with information about
+	// the error.
+	URI string
+	// Status overrides the HTTP status code of error responses.
+	Status int
+	// Header holds additional headers of error responses.
+	Header http.Header
+	// Cause is the internal cause of the error. It is logged but never
+	// sent to the client. It is not unwrapped, so that errors.Is never
+	// matches a sentinel error of the cause instead of the code.
+	Cause error
+}
+
+// NewError creates a new error with the code of the sentinel error and
+// the description.
+func NewError(err error, description string) *Error {
+	return &Error{Code: errorCode(err), Description: description}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Is reports whether the target is the sentinel error, or an Error, with
+// the same code.
+func (e *Error) Is(target error) bool {
+	if t, ok := target.(*Error); ok {
+		return t.Code == e.Code
+	}
+	sentinel, ok := sentinelErrors[e.Code]
+	return ok && target == sentinel
+}
+
+// sentinelErrors maps the error codes to their sentinel errors.
+var sentinelErrors = map[string]error{
+	ErrInvalidRequest.Error():              ErrInvalidRequest,
+	ErrInvalidClient.Error():               ErrInvalidClient,
+	ErrInvalidGrant.Error():                ErrInvalidGrant,
+	ErrUnauthorizedClient.Error():          ErrUnauthorizedClient,
+	ErrUnsupportedGrantType.Error():        ErrUnsupportedGrantType,
+	ErrInvalidScope.Error():                ErrInvalidScope,
+	ErrAccessDenied.Error():                ErrAccessDenied,
+	ErrUnsupportedResponseType.Error():     ErrUnsupportedResponseType,
+	ErrServerError.Error():                 ErrServerError,
+	ErrTemporarilyUnavailable.Error():      ErrTemporarilyUnavailable,
+	ErrInvalidAuthorizationDetails.Error(): ErrInvalidAuthorizationDetails,
+	ErrInvalidTarget.Error():               ErrInvalidTarget,
+	ErrLoginRequired.Error():               ErrLoginRequired,
+	ErrConsentRequired.Error():             ErrConsentRequired,
+	ErrAuthorizationPending.Error():        ErrAuthorizationPending,
+	ErrSlowDown.Error():                    ErrSlowDown,
+	ErrExpiredToken.Error():                ErrExpiredToken,
+	ErrUnknownUserID.Error():               ErrUnknownUserID,
+	ErrExpiredLoginHintToken.Error():       ErrExpiredLoginHintToken,
+	ErrInvalidBindingMessage.Error():       ErrInvalidBindingMessage,
+	ErrMissingUserCode.Error():             ErrMissingUserCode,
+	ErrInvalidUserCode.Error():             ErrInvalidUserCode,
+}
+
+// errorCode returns the error code of the error.
+func errorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return err.Error()
+}
+
+// isError reports whether the error matches one of the targets.
+func isError(err error, targets ...error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceError returns the Error of a service, or fallback if the
+// service did not return one.
+func serviceError(err, fallback error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return fallback
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventType is the type of an audit event.
+type EventType string
+
+// The audit event types.
+const (
+	EventTokenIssued                EventType = "token_issued"
+	EventTokenRefused               EventType = "token_refused"
+	EventAuthorizationIssued        EventType = "authorization_issued"
+	EventAuthorizationRefused       EventType = "authorization_refused"
+	EventClientAuthenticationFailed EventType = "client_authentication_failed"
+	EventTokenRevoked               EventType = "token_revoked"
+	EventRefreshTokenReuse          EventType = "refresh_token_reuse"
+	EventRateLimited                EventType = "rate_limited"
+	EventUsernameAttemptsExceeded   EventType = "username_attempts_exceeded"
+	EventBackChannelLogoutFailed    EventType = "back_channel_logout_failed"
+	EventRevocationRefused          EventType = "revocation_refused"
+	EventIntrospectionRefused       EventType = "introspection_refused"
+	EventLogoutRefused              EventType = "logout_refused"
+
+	EventBackchannelAuthenticationStarted EventType = "backchannel_authentication_started"
+	EventBackchannelAuthenticationRefused EventType = "backchannel_authentication_refused"
+)
+
+// The outcomes of an audit event.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is an audit event of a protocol decision.
+type Event struct {
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	ClientID  string    `json:"client_id,omitempty"`
+	GrantType string    `json:"grant_type,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	RemoteIP  string    `json:"remote_ip,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// EventSink receives the audit events of the handler and the grant
+// types. It is called synchronously and must be safe for concurrent
+// use.
+type EventSink interface {
+	Event(ctx context.Context, event *Event)
+}
+
+// eventRecorder completes the events of a request with its common
+// fields before passing them to the sink.
+type eventRecorder struct {
+	sink    EventSink
+	metrics *Metrics
+	refused EventType
+	base    Event
+	// client holds the identifier of the authenticated client.
+	client string
+	// err holds the error code of the last failed event.
+	err string
+}
+
+// withEvents attaches an event recorder to the request, if the handler
+// has an EventSink or Metrics. Refusals of the request are recorded as
+// refused. It does not parse the request, so that refusals of strict
+// request validation are recorded; describeRequest completes the events
+// once the request is validated.
+func (h *Handler) withEvents(req *http.Request, refused EventType) *http.Request {
+	if h.EventSink == nil && h.Metrics == nil {
+		return req
+	}
+
+	requestID := req.Header.Get("X-Request-Id")
+	if h.RequestID != nil {
+		requestID = h.RequestID(req)
+	}
+
+	return withContextValue(req, eventsKey, &eventRecorder{
+		sink:    h.EventSink,
+		metrics: h.Metrics,
+		refused: refused,
+		base: Event{
+			RemoteIP:  h.remoteIP(req),
+			RequestID: requestID,
+		},
+	})
+}
+
+// describeRequest sets the client and scopes of all further events of
+// the request from its parameters.
+func describeRequest(req *http.Request) {
+	if recorder := eventsFromContext(req.Context()); recorder != nil {
+		recorder.base.ClientID, _ = clientCredentials(req)
+		recorder.base.Scopes = parseScopes(req.FormValue("scope"))
+	}
+}
+
+// remoteIP returns the IP of the request.
+func (h *Handler) remoteIP(req *http.Request) string {
+	if h.RemoteIP != nil {
+		return h.RemoteIP(req)
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func eventsFromContext(ctx context.Context) *eventRecorder {
+	recorder, _ := ctx.Value(eventsKey).(*eventRecorder)
+	return recorder
+}
+
+// describeEvents sets the grant type and subject of all further events
+// of the request.
+func describeEvents(ctx context.Context, grantType, subject string) {
+	if recorder := eventsFromContext(ctx); recorder != nil {
+		recorder.base.GrantType = grantType
+		recorder.base.Subject = subject
+	}
+}
+
+// setEventClient records the authenticated client of the request.
+func setEventClient(ctx context.Context, client Client) {
+	if recorder := eventsFromContext(ctx); recorder != nil {
+		recorder.client = client.Identifier()
+	}
+}
+
+// emitEvent passes the event to the EventSink of the handler, if any.
+// Empty fields are filled from the request.
+func emitEvent(ctx context.Context, event *Event) {
+	recorder := eventsFromContext(ctx)
+	if recorder == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.ClientID == "" {
+		event.ClientID = recorder.base.ClientID
+	}
+	if event.GrantType == "" {
+		event.GrantType = recorder.base.GrantType
+	}
+	if event.Subject == "" {
+		event.Subject = recorder.base.Subject
+	}
+	if event.Subject == "" {
+		if session := SessionFromContext(ctx); session != nil {
+			event.Subject = session.Subject
+		}
+	}
+	if event.Scopes == nil {
+		event.Scopes = GrantedScopesFromContext(ctx)
+	}
+	if event.Scopes == nil {
+		event.Scopes = recorder.base.Scopes
+	}
+	if event.Outcome == "" {
+		event.Outcome = OutcomeSuccess
+		if event.Error != "" {
+			event.Outcome = OutcomeFailure
+		}
+	}
+	event.RemoteIP = recorder.base.RemoteIP
+	event.RequestID = recorder.base.RequestID
+
+	if event.Outcome == OutcomeFailure {
+		recorder.err = event.Error
+	}
+	if recorder.metrics != nil {
+		recorder.metrics.event(event)
+	}
+	if recorder.sink != nil {
+		recorder.sink.Event(ctx, event)
+	}
+}
+
+// emitRefused emits the refusal of the request with the error code.
+func emitRefused(ctx context.Context, code string) {
+	if recorder := eventsFromContext(ctx); recorder != nil && recorder.refused != "" {
+		emitEvent(ctx, &Event{Type: recorder.refused, Error: code})
+	}
+}
+
+// refuse writes the error and emits the refusal of the request.
+func (h *Handler) refuse(w http.ResponseWriter, req *http.Request, status int, err error, state string) {
+	emitRefused(req.Context(), errorCode(err))
+	writeError(w, h.logger, status, err, state)
+}
+
+// NewJSONEventSink creates a new event sink writing one JSON object per
+// event and line to w.
+func NewJSONEventSink(w io.Writer, logger Log) EventSink {
+	return &jsonEventSink{logger: logger, enc: json.NewEncoder(w)}
+}
+
+type jsonEventSink struct {
+	logger Log
+	mu     sync.Mutex
+	enc    *json.Encoder
+}
+
+func (s *jsonEventSink) Event(ctx context.Context, event *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(event); err != nil && s.logger != nil {
+		s.logger.Println(err)
+	}
+}
+
+// NewSlogEventSink creates a new event sink logging events to logger,
+// at info level if they succeeded and at warn level otherwise.
+func NewSlogEventSink(logger *slog.Logger) EventSink {
+	return &slogEventSink{logger}
+}
+
+type slogEventSink struct {
+	logger *slog.Logger
+}
+
+func (s *slogEventSink) Event(ctx context.Context, event *Event) {
+	level := slog.LevelInfo
+	if event.Outcome != OutcomeSuccess {
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("type", string(event.Type)),
+		slog.String("outcome", event.Outcome),
+	}
+	for _, attr := range []struct{ key, value string }{
+		{"client_id", event.ClientID},
+		{"grant_type", event.GrantType},
+		{"subject", event.Subject},
+		{"error", event.Error},
+		{"remote_ip", event.RemoteIP},
+		{"request_id", event.RequestID},
+	} {
+		if attr.value != "" {
+			attrs = append(attrs, slog.String(attr.key, attr.value))
+		}
+	}
+	if len(event.Scopes) > 0 {
+		attrs = append(attrs, slog.Any("scopes", event.Scopes))
+	}
+
+	s.logger.LogAttrs(ctx, level, "oauth2 "+string(event.Type), attrs...)
+}
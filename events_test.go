@@ -0,0 +1,136 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testStorer map[string]Client
+
+func (s testStorer) FindClient(ctx context.Context, id string) (Client, error) {
+	return s[id], nil
+}
+
+type testPasswordService struct{}
+
+func (testPasswordService) PasswordGrantTypeResponse(ctx context.Context, client Client, username, password string, issueRefreshToken bool) (*AccessResponse, error) {
+	if password != "secret" {
+		return nil, ErrAccessDenied
+	}
+	return &AccessResponse{AccessToken: "token", TokenType: "bearer", Info: map[string]interface{}{}}, nil
+}
+
+func TestTokenEvents(t *testing.T) {
+	storer := testStorer{"foo": &testClient{id: "foo", secret: "bar", grantTypes: []string{PasswordGrantType}}}
+
+	var buf bytes.Buffer
+	h := NewHandler(storer, nil, NewPasswordGrantType(nil, testPasswordService{}))
+	h.EventSink = NewJSONEventSink(&buf, nil)
+
+	tests := []struct {
+		clientSecret string
+		password     string
+		expected     []Event
+	}{
+		{"wrong", "secret", []Event{
+			{Type: EventClientAuthenticationFailed, Outcome: OutcomeFailure, Error: "invalid_client"},
+			{Type: EventTokenRefused, Outcome: OutcomeFailure, Error: "invalid_client"},
+		}},
+		{"bar", "wrong", []Event{
+			{Type: EventTokenRefused, Outcome: OutcomeFailure, Error: "invalid_grant"},
+		}},
+		{"bar", "secret", []Event{
+			{Type: EventTokenIssued, Outcome: OutcomeSuccess},
+		}},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+
+		form := url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {tt.password}, "scope": {"read"}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Request-Id", "req-1")
+		req.SetBasicAuth("foo", tt.clientSecret)
+		h.Token(httptest.NewRecorder(), req)
+
+		dec := json.NewDecoder(&buf)
+		for _, expected := range tt.expected {
+			var got Event
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("Token(%s, %s) => %v, expected event %s", tt.clientSecret, tt.password, err, expected.Type)
+			}
+			if got.Type != expected.Type || got.Outcome != expected.Outcome || got.Error != expected.Error {
+				t.Errorf("Token(%s, %s) => %+v, expected %+v", tt.clientSecret, tt.password, got, expected)
+			}
+			if got.ClientID != "foo" || got.GrantType != PasswordGrantType || got.Subject != "alice" || got.RequestID != "req-1" || got.RemoteIP != "192.0.2.1" || len(got.Scopes) != 1 || got.Time.IsZero() {
+				t.Errorf("Token(%s, %s) => %+v, expected request fields", tt.clientSecret, tt.password, got)
+			}
+		}
+		if dec.More() {
+			t.Errorf("Token(%s, %s) => unexpected events %s", tt.clientSecret, tt.password, buf.String())
+		}
+	}
+}
+
+func TestRefuseRecordsErrorCode(t *testing.T) {
+	events := make(testEventSink, 1)
+	h := NewHandler(testStorer{}, nil)
+	h.EventSink = events
+
+	req := h.withEvents(httptest.NewRequest("POST", "/token", nil), EventTokenRefused)
+	h.refuse(httptest.NewRecorder(), req, 500, ErrServerError, "")
+
+	if event := <-events; event.Type != EventTokenRefused || event.Error != "server_error" {
+		t.Errorf("refuse(%v) => %+v, expected error code server_error", ErrServerError, event)
+	}
+}
+
+func TestRefusedEvents(t *testing.T) {
+	events := make(testEventSink, 4)
+	h := NewHandler(testStorer{}, nil)
+	h.EventSink = events
+	h.StrictRequests = true
+	h.TokenStore = NewMemoryTokenStore()
+	h.LogoutService = testLogoutService{}
+
+	form := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tests := []struct {
+		name     string
+		handle   http.HandlerFunc
+		req      *http.Request
+		expected EventType
+		code     string
+	}{
+		{"Token", h.Token, httptest.NewRequest(http.MethodGet, "/token", nil), EventTokenRefused, "invalid_request"},
+		{"Authorize", h.Authorize, httptest.NewRequest(http.MethodPut, "/authorize", nil), EventAuthorizationRefused, "invalid_request"},
+		{"Revoke", h.Revoke, form(""), EventRevocationRefused, "invalid_request"},
+		{"Introspect", h.Introspect, form("token=foo"), EventIntrospectionRefused, "invalid_request"},
+		{"EndSession", h.EndSession, httptest.NewRequest(http.MethodGet, "/logout?id_token_hint=invalid", nil), EventLogoutRefused, "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		tt.handle(httptest.NewRecorder(), tt.req)
+
+		var got *Event
+		for len(events) > 0 {
+			got = <-events
+		}
+		if got == nil || got.Type != tt.expected || got.Error != tt.code {
+			t.Errorf("%s => %+v, expected %s with %s", tt.name, got, tt.expected, tt.code)
+		}
+	}
+}
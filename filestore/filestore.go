@@ -0,0 +1,282 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+// Package filestore implements an oauth2.Storer loading declarative
+// client definitions from a JSON or YAML file:
+//
+//	{
+//		"clients": [
+//			{
+//				"id": "web",
+//				"secret_hash": "pbkdf2-sha256$...",
+//				"confidential": true,
+//				"redirect_uris": ["https://app.example.com/callback"],
+//				"grant_types": ["implicit", "refresh"],
+//				"scopes": ["read", "write"]
+//			}
+//		]
+//	}
+//
+// Files ending in .yaml or .yml are read as YAML, using the same keys:
+//
+//	clients:
+//	  - id: web
+//	    secret_hash: pbkdf2-sha256$...
+//	    confidential: true
+//	    redirect_uris: [https://app.example.com/callback]
+//	    grant_types: [implicit, refresh]
+//	    scopes: [read, write]
+//
+// Secret hashes are created with oauth2.HashSecret. Files with secret
+// hashes in a format unknown to oauth2.DefaultSecretVerifier, or with
+// cost parameters beyond its limits, do not validate.
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danilobuerger/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+var _ oauth2.MultiSecretClient = (*Client)(nil)
+
+// Client is a client defined in the file. Confidential clients
+// authenticate with the secret hashed in SecretHash, verified by the
+// SecretVerifier of the handler.
+type Client struct {
+	ID           string   `json:"id" yaml:"id"`
+	SecretHash   string   `json:"secret_hash,omitempty" yaml:"secret_hash,omitempty"`
+	Confidential bool     `json:"confidential" yaml:"confidential"`
+	RedirectURIs []string `json:"redirect_uris" yaml:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types" yaml:"grant_types"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// Identifier returns the client identifier.
+func (c *Client) Identifier() string {
+	return c.ID
+}
+
+// IsAllowedRedirectURI reports whether the redirection URI matches a
+// registered one.
+func (c *Client) IsAllowedRedirectURI(uri string) bool {
+	return oauth2.DefaultRedirectURIMatcher.Match(c.RedirectURIs, uri)
+}
+
+// RegisteredRedirectURIs returns the registered redirection URIs.
+func (c *Client) RegisteredRedirectURIs() []string {
+	return c.RedirectURIs
+}
+
+// IsAllowedGrantType reports whether the client may use the grant type.
+func (c *Client) IsAllowedGrantType(identifier string) bool {
+	return contains(c.GrantTypes, identifier)
+}
+
+// IsConfidential reports whether the client is confidential.
+func (c *Client) IsConfidential() bool {
+	return c.Confidential
+}
+
+// Authenticate compares the secret with the hashed secret.
+func (c *Client) Authenticate(secret string) bool {
+	return c.Confidential && oauth2.CompareSecret(c.SecretHash, secret)
+}
+
+// ClientSecrets returns the hashed secret of a confidential client.
+func (c *Client) ClientSecrets() []oauth2.ClientSecret {
+	if !c.Confidential {
+		return nil
+	}
+	return []oauth2.ClientSecret{{Hash: c.SecretHash}}
+}
+
+var _ oauth2.Storer = (*Registry)(nil)
+
+// Registry finds clients defined in a file.
+type Registry struct {
+	path    string
+	logger  oauth2.Log
+	clients atomic.Value // map[string]*Client
+
+	mu      sync.Mutex
+	data    []byte
+	modTime time.Time
+}
+
+// New creates a new registry loading the clients from the file at path.
+// It fails if the file cannot be read or does not validate.
+func New(path string, logger oauth2.Log) (*Registry, error) {
+	r := &Registry{path: path, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FindClient finds the client by its identifier. It returns nil if the
+// client is not defined.
+func (r *Registry) FindClient(ctx context.Context, id string) (oauth2.Client, error) {
+	clients := r.clients.Load().(map[string]*Client)
+	if c, ok := clients[id]; ok {
+		return c, nil
+	}
+	return nil, nil
+}
+
+// Reload loads the file and atomically replaces the clients. If the file
+// cannot be read or does not validate, the last good clients are kept
+// and the error is returned.
+func (r *Registry) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.modTime = fi.ModTime()
+
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	if r.data != nil && bytes.Equal(data, r.data) {
+		return nil
+	}
+
+	clients, err := parse(data, isYAML(r.path))
+	if err != nil {
+		return fmt.Errorf("filestore: %s: %v", r.path, err)
+	}
+
+	r.clients.Store(clients)
+	r.data = data
+	return nil
+}
+
+// Watch reloads the file whenever it changes, checking every interval
+// until the context is done. Failed reloads are logged. It is typically
+// run in its own goroutine.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(r.path)
+		if err != nil {
+			r.log(err)
+			continue
+		}
+		r.mu.Lock()
+		changed := !fi.ModTime().Equal(r.modTime)
+		r.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			r.log(err)
+		}
+	}
+}
+
+func (r *Registry) log(err error) {
+	if r.logger != nil {
+		r.logger.Println(err)
+	}
+}
+
+// isYAML reports whether the file at path is read as YAML.
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func parse(data []byte, isYAML bool) (map[string]*Client, error) {
+	var file struct {
+		Clients []*Client `json:"clients" yaml:"clients"`
+	}
+
+	if isYAML {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && err != io.EOF {
+			return nil, err
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return nil, err
+		}
+	}
+
+	clients := make(map[string]*Client, len(file.Clients))
+	for i, c := range file.Clients {
+		if err := validate(c); err != nil {
+			return nil, fmt.Errorf("client %d: %v", i, err)
+		}
+		if _, ok := clients[c.ID]; ok {
+			return nil, fmt.Errorf("client %d: duplicate id %s", i, c.ID)
+		}
+		clients[c.ID] = c
+	}
+
+	return clients, nil
+}
+
+func validate(c *Client) error {
+	if c.ID == "" {
+		return errors.New("missing id")
+	}
+	if c.Confidential && c.SecretHash == "" {
+		return errors.New("confidential client without secret_hash")
+	}
+	if c.SecretHash != "" {
+		if err := oauth2.DefaultSecretVerifier.Validate(c.SecretHash); err != nil {
+			return fmt.Errorf("invalid secret_hash: %v", err)
+		}
+	}
+	if !c.Confidential && c.SecretHash != "" {
+		return errors.New("public client with secret_hash")
+	}
+	if len(c.GrantTypes) == 0 {
+		return errors.New("missing grant_types")
+	}
+
+	for _, uri := range c.RedirectURIs {
+		if err := oauth2.DefaultRedirectURIMatcher.Validate(uri); err != nil {
+			return fmt.Errorf("invalid redirect uri %s: %v", uri, err)
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
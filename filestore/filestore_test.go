@@ -0,0 +1,154 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package filestore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+func TestRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash, err := oauth2.HashSecret("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "clients.json")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"clients": [
+		{"id": "web", "secret_hash": "` + hash + `", "confidential": true, "redirect_uris": ["https://app.example.com/cb"], "grant_types": ["implicit"]}
+	]}`)
+
+	r, err := New(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client, _ := r.FindClient(ctx, "web")
+	if client == nil || !client.Authenticate("secret") || client.Authenticate("wrong") || !client.IsAllowedGrantType(oauth2.ImplicitGrantType) {
+		t.Errorf("FindClient(web) => %+v, expected confidential web client", client)
+	}
+	if msc, ok := client.(oauth2.MultiSecretClient); !ok || oauth2.DefaultSecretVerifier.Verify(msc.ClientSecrets(), "secret", time.Now()) == nil {
+		t.Errorf("FindClient(web) => %+v, expected secret verified by the SecretVerifier", client)
+	}
+
+	invalid := []string{
+		`{"clients": [{"id": "", "grant_types": ["implicit"]}]}`,
+		`{"clients": [{"id": "web", "confidential": true, "grant_types": ["implicit"]}]}`,
+		`{"clients": [{"id": "web", "secret_hash": "secret", "confidential": true, "grant_types": ["implicit"]}]}`,
+		`{"clients": [{"id": "web", "secret_hash": "$scrypt$ln=31,r=8,p=1$c2FsdA$a2V5", "confidential": true, "grant_types": ["implicit"]}]}`,
+		`{"clients": [{"id": "web", "grant_types": ["implicit"], "redirect_uris": ["/cb"]}]}`,
+		`{"clients": [{"id": "web", "grant_types": ["implicit"]}, {"id": "web", "grant_types": ["implicit"]}]}`,
+		`{"clients": [{"id": "web", "grant_types": ["implicit"], "unknown": true}]}`,
+		`{"clients": [`,
+	}
+	for _, data := range invalid {
+		write(data)
+		if err := r.Reload(); err == nil {
+			t.Errorf("Reload(%s) => nil, expected error", data)
+		}
+		if client, _ := r.FindClient(ctx, "web"); client == nil || !client.IsConfidential() {
+			t.Errorf("FindClient(web) after failed reload => %+v, expected last good client", client)
+		}
+	}
+
+	write(`{"clients": [{"id": "spa", "redirect_uris": ["https://spa.example.com/cb"], "grant_types": ["implicit"]}]}`)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	go r.Watch(watchCtx, time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if client, _ := r.FindClient(ctx, "spa"); client != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Watch did not reload the changed file")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if client, _ := r.FindClient(ctx, "web"); client != nil {
+		t.Errorf("FindClient(web) after reload => %+v, expected nil", client)
+	}
+}
+
+func TestRegistryYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash, err := oauth2.HashSecret("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "clients.yaml")
+	data := `# Clients of the example deployment.
+clients:
+  - id: web
+    secret_hash: ` + hash + `
+    confidential: true
+    redirect_uris:
+      - https://app.example.com/cb
+    grant_types: [implicit]
+  - id: cli
+    confidential: false
+    redirect_uris: ['http://127.0.0.1/cb']
+    grant_types: [implicit]
+`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if client, _ := r.FindClient(ctx, "web"); client == nil || !client.Authenticate("secret") || !client.IsAllowedRedirectURI("https://app.example.com/cb") {
+		t.Errorf("FindClient(web) => %+v, expected confidential web client", client)
+	}
+	if client, _ := r.FindClient(ctx, "cli"); client == nil || client.IsConfidential() || !client.IsAllowedGrantType(oauth2.ImplicitGrantType) {
+		t.Errorf("FindClient(cli) => %+v, expected public cli client", client)
+	}
+
+	invalid := []string{
+		"clients:\n  - id: web\n    unknown: true\n",
+		"clients:\n  - id: web\n    confidential: true\n    secret_hash: plain\n    grant_types: [implicit]\n",
+		"clients:\n  - id: [web\n",
+	}
+	for _, data := range invalid {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Reload(); err == nil {
+			t.Errorf("Reload(%q) => nil, expected error", data)
+		}
+		if client, _ := r.FindClient(ctx, "cli"); client == nil {
+			t.Error("FindClient(cli) after failed reload => nil, expected last good client")
+		}
+	}
+}
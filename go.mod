@@ -1,3 +1,10 @@
 module github.com/danilobuerger/oauth2
 
-go 1.14
+go 1.21
+
+require (
+	golang.org/x/crypto v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,401 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CIBAGrantType (client initiated backchannel authentication) is used
+// by the client to initiate the authentication of an end-user by means
+// of out-of-band mechanisms, e.g. an authentication device such as the
+// end-user's phone, rather than redirecting the end-user's user agent.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html
+const CIBAGrantType = "ciba"
+
+// CIBA token delivery modes.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.5
+const (
+	CIBAPollMode = "poll"
+	CIBAPingMode = "ping"
+	CIBAPushMode = "push"
+)
+
+// CIBAClient is a client registered for CIBA. Clients not implementing
+// it use the poll mode.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.4
+type CIBAClient interface {
+	Client
+	BackchannelTokenDeliveryMode() string
+	BackchannelClientNotificationEndpoint() string
+}
+
+// BackchannelAuthenticationRequest is a validated backchannel
+// authentication request.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1
+type BackchannelAuthenticationRequest struct {
+	Client                  Client
+	DeliveryMode            string
+	Scopes                  []string
+	LoginHint               string
+	IDTokenHint             string
+	LoginHintToken          string
+	BindingMessage          string
+	UserCode                string
+	RequestedExpiry         int64
+	ClientNotificationToken string
+}
+
+// BackchannelAuthentication is a started backchannel authentication.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.3
+type BackchannelAuthentication struct {
+	AuthReqID string
+	ExpiresIn int64
+	// Interval is the minimum amount of seconds the client must wait
+	// between polling requests.
+	Interval int64
+}
+
+// CIBAGrantTypeService starts backchannel authentications through the
+// user notification channel of the authorization server and returns
+// access responses once the end-user approved them.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html
+type CIBAGrantTypeService interface {
+	// StartBackchannelAuthentication identifies the end-user from the
+	// hint and asks them to approve the request. It returns
+	// ErrUnknownUserID, ErrExpiredLoginHintToken,
+	// ErrInvalidBindingMessage, ErrMissingUserCode or ErrInvalidUserCode
+	// if the request cannot be started.
+	StartBackchannelAuthentication(ctx context.Context, req *BackchannelAuthenticationRequest) (*BackchannelAuthentication, error)
+
+	// CIBAGrantTypeResponse returns an access response once the end-user
+	// approved the request. It returns ErrAuthorizationPending,
+	// ErrSlowDown, ErrExpiredToken or ErrAccessDenied otherwise.
+	//
+	// In ping and push mode the service notifies the client once the
+	// end-user decided, e.g. with a CIBANotifier.
+	CIBAGrantTypeResponse(ctx context.Context, client Client, authReqID string) (*AccessResponse, error)
+}
+
+// CIBAUserCodeService is a CIBAGrantTypeService supporting the
+// user_code parameter. Only then the metadata advertises it.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.4
+type CIBAUserCodeService interface {
+	CIBAGrantTypeService
+	BackchannelUserCodeParameterSupported() bool
+}
+
+// BackchannelAuthenticationGrantType is a grant type on the
+// /bc-authorize endpoint.
+type BackchannelAuthenticationGrantType interface {
+	TokenGrantType
+	StartBackchannelAuthentication(req *http.Request, client Client) (*BackchannelAuthentication, error)
+}
+
+// NewCIBAGrantType creates a new grant type.
+func NewCIBAGrantType(logger Log, service CIBAGrantTypeService) GrantType {
+	return &cibaGT{logger, service}
+}
+
+var _ GrantType = (*cibaGT)(nil)
+var _ TokenGrantType = (*cibaGT)(nil)
+var _ BackchannelAuthenticationGrantType = (*cibaGT)(nil)
+
+type cibaGT struct {
+	logger  Log
+	service CIBAGrantTypeService
+}
+
+func (gt *cibaGT) Identifier() string {
+	return CIBAGrantType
+}
+
+func (gt *cibaGT) GrantName() string {
+	return "urn:openid:params:grant-type:ciba"
+}
+
+// BackchannelUserCodeParameterSupported reports whether the service
+// supports the user_code parameter.
+func (gt *cibaGT) BackchannelUserCodeParameterSupported() bool {
+	s, ok := gt.service.(CIBAUserCodeService)
+	return ok && s.BackchannelUserCodeParameterSupported()
+}
+
+func (gt *cibaGT) StartBackchannelAuthentication(req *http.Request, client Client) (*BackchannelAuthentication, error) {
+	if !client.IsConfidential() {
+		return nil, ErrInvalidClient
+	}
+
+	bcReq := &BackchannelAuthenticationRequest{
+		Client:                  client,
+		DeliveryMode:            cibaDeliveryMode(client),
+		Scopes:                  parseScopes(req.PostFormValue("scope")),
+		LoginHint:               req.PostFormValue("login_hint"),
+		IDTokenHint:             req.PostFormValue("id_token_hint"),
+		LoginHintToken:          req.PostFormValue("login_hint_token"),
+		BindingMessage:          req.PostFormValue("binding_message"),
+		UserCode:                req.PostFormValue("user_code"),
+		ClientNotificationToken: req.PostFormValue("client_notification_token"),
+	}
+
+	if !containsString(bcReq.Scopes, "openid") {
+		return nil, ErrInvalidScope
+	}
+
+	hints := 0
+	for _, hint := range []string{bcReq.LoginHint, bcReq.IDTokenHint, bcReq.LoginHintToken} {
+		if hint != "" {
+			hints++
+		}
+	}
+	if hints != 1 {
+		return nil, ErrInvalidRequest
+	}
+
+	if v := req.PostFormValue("requested_expiry"); v != "" {
+		expiry, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || expiry <= 0 {
+			return nil, ErrInvalidRequest
+		}
+		bcReq.RequestedExpiry = expiry
+	}
+
+	if bcReq.DeliveryMode != CIBAPollMode && bcReq.ClientNotificationToken == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	auth, err := gt.service.StartBackchannelAuthentication(req.Context(), bcReq)
+	if err != nil {
+		if isError(err, ErrUnknownUserID, ErrExpiredLoginHintToken, ErrInvalidBindingMessage, ErrMissingUserCode, ErrInvalidUserCode, ErrAccessDenied) {
+			return nil, err
+		}
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, serviceError(err, ErrServerError)
+	}
+
+	if bcReq.DeliveryMode == CIBAPushMode {
+		auth.Interval = 0
+	}
+
+	return auth, nil
+}
+
+func (gt *cibaGT) Grant(req *http.Request, client Client) (*AccessResponse, error) {
+	authReqID := req.PostFormValue("auth_req_id")
+	if authReqID == "" {
+		return nil, ErrInvalidRequest
+	}
+
+	// Clients in push mode receive their tokens at the client
+	// notification endpoint.
+	if cibaDeliveryMode(client) == CIBAPushMode {
+		return nil, ErrUnauthorizedClient
+	}
+
+	access, err := gt.service.CIBAGrantTypeResponse(req.Context(), client, authReqID)
+	if err != nil {
+		if isError(err, ErrAuthorizationPending, ErrSlowDown, ErrExpiredToken, ErrAccessDenied) {
+			return nil, err
+		}
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, serviceError(err, ErrInvalidGrant)
+	}
+
+	emitEvent(req.Context(), &Event{Type: EventTokenIssued})
+
+	return access, nil
+}
+
+func cibaDeliveryMode(client Client) string {
+	if cc, ok := client.(CIBAClient); ok && cc.BackchannelTokenDeliveryMode() != "" {
+		return cc.BackchannelTokenDeliveryMode()
+	}
+	return CIBAPollMode
+}
+
+// BackchannelAuthorize is used by the client to initiate the
+// authentication of an end-user out-of-band.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7
+func (h *Handler) BackchannelAuthorize(w http.ResponseWriter, req *http.Request) {
+	req = h.withEvents(req, EventBackchannelAuthenticationRefused)
+	if h.Metrics != nil {
+		defer h.Metrics.observe("bc-authorize", eventsFromContext(req.Context()), time.Now())
+	}
+
+	if h.StrictRequests {
+		if !h.validateTokenRequest(w, req) {
+			return
+		}
+	} else if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.refuse(w, req, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+		return
+	}
+	describeRequest(req)
+
+	var grantType BackchannelAuthenticationGrantType
+	for _, gt := range h.tokenGTs {
+		if bgt, ok := gt.(BackchannelAuthenticationGrantType); ok {
+			grantType = bgt
+			break
+		}
+	}
+	if grantType == nil {
+		h.refuse(w, req, http.StatusBadRequest, ErrUnsupportedGrantType, "")
+		return
+	}
+	describeEvents(req.Context(), grantType.Identifier(), "")
+
+	limitKeys := h.rateLimitKeys(req, "")
+	if !h.limitRequest(w, req, limitKeys, "") {
+		return
+	}
+
+	client, req, err := h.clientFromRequest(req, grantType)
+	if errors.Is(err, ErrInvalidClient) {
+		h.recordAuthentication(req.Context(), limitKeys.clientLockout, false)
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		h.refuse(w, req, http.StatusUnauthorized, err, "")
+		return
+	} else if errors.Is(err, ErrServerError) {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	} else if err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	h.recordAuthentication(req.Context(), limitKeys.clientLockout, true)
+
+	auth, err := grantType.StartBackchannelAuthentication(req, client)
+	if errors.Is(err, ErrInvalidClient) {
+		h.refuse(w, req, http.StatusUnauthorized, err, "")
+		return
+	} else if errors.Is(err, ErrServerError) {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	} else if errors.Is(err, ErrAccessDenied) {
+		h.refuse(w, req, http.StatusForbidden, err, "")
+		return
+	} else if err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	emitEvent(req.Context(), &Event{Type: EventBackchannelAuthenticationStarted})
+
+	resp := map[string]interface{}{
+		"auth_req_id": auth.AuthReqID,
+		"expires_in":  auth.ExpiresIn,
+	}
+	if auth.Interval > 0 {
+		resp["interval"] = auth.Interval
+	}
+
+	writeJSON(w, h.logger, http.StatusOK, resp, map[string]string{
+		"Cache-Control": "no-store",
+		"Pragma":        "no-cache",
+	})
+}
+
+// CIBANotifier notifies clients in ping and push mode of the completion
+// of backchannel authentications.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10
+type CIBANotifier struct {
+	httpClient *http.Client
+}
+
+// NewCIBANotifier creates a new notifier sending notifications with
+// httpClient.
+func NewCIBANotifier(httpClient *http.Client) *CIBANotifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &CIBANotifier{httpClient}
+}
+
+// Ping notifies a client in ping mode that it can retrieve its tokens
+// from the token endpoint.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.2
+func (n *CIBANotifier) Ping(ctx context.Context, client CIBAClient, notificationToken, authReqID string) error {
+	return n.notify(ctx, client, notificationToken, map[string]interface{}{
+		"auth_req_id": authReqID,
+	})
+}
+
+// Push delivers the tokens to a client in push mode.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.3.1
+func (n *CIBANotifier) Push(ctx context.Context, client CIBAClient, notificationToken, authReqID string, access *AccessResponse) error {
+	m := access.ToMap()
+	m["auth_req_id"] = authReqID
+	return n.notify(ctx, client, notificationToken, m)
+}
+
+// PushError delivers an error to a client in push mode, e.g.
+// ErrAccessDenied or ErrExpiredToken.
+//
+// https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.12
+func (n *CIBANotifier) PushError(ctx context.Context, client CIBAClient, notificationToken, authReqID string, err error) error {
+	payload := map[string]interface{}{
+		"auth_req_id": authReqID,
+		"error":       errorCode(err),
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.Description != "" {
+		payload["error_description"] = e.Description
+	}
+
+	return n.notify(ctx, client, notificationToken, payload)
+}
+
+func (n *CIBANotifier) notify(ctx context.Context, client CIBAClient, notificationToken string, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, client.BackchannelClientNotificationEndpoint(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+notificationToken)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("oauth2: client notification to %s failed with status %d", req.URL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testCIBAClient struct {
+	*testClient
+	mode     string
+	endpoint string
+}
+
+func (c testCIBAClient) BackchannelTokenDeliveryMode() string {
+	return c.mode
+}
+
+func (c testCIBAClient) BackchannelClientNotificationEndpoint() string {
+	return c.endpoint
+}
+
+type testCIBAService struct{}
+
+func (testCIBAService) StartBackchannelAuthentication(ctx context.Context, req *BackchannelAuthenticationRequest) (*BackchannelAuthentication, error) {
+	switch req.LoginHint {
+	case "alice":
+	case "carol":
+		return nil, NewError(ErrUnknownUserID, "The user is unknown.")
+	default:
+		return nil, ErrUnknownUserID
+	}
+	return &BackchannelAuthentication{AuthReqID: "req-1", ExpiresIn: 120, Interval: 5}, nil
+}
+
+func (testCIBAService) CIBAGrantTypeResponse(ctx context.Context, client Client, authReqID string) (*AccessResponse, error) {
+	switch authReqID {
+	case "pending":
+		return nil, ErrAuthorizationPending
+	case "slow":
+		return nil, ErrSlowDown
+	case "slower":
+		return nil, NewError(ErrSlowDown, "Poll less often.")
+	case "expired":
+		return nil, ErrExpiredToken
+	case "approved":
+		return &AccessResponse{AccessToken: "token", TokenType: "bearer", Info: map[string]interface{}{}}, nil
+	}
+	return nil, ErrInvalidGrant
+}
+
+func newTestCIBAHandler() *Handler {
+	storer := testStorer{
+		"poll": &testClient{id: "poll", secret: "secret", grantTypes: []string{CIBAGrantType}},
+		"push": testCIBAClient{&testClient{id: "push", secret: "secret", grantTypes: []string{CIBAGrantType}}, CIBAPushMode, "https://client.example.com/cb"},
+	}
+	return NewHandler(storer, nil, NewCIBAGrantType(nil, testCIBAService{}))
+}
+
+func TestBackchannelAuthorize(t *testing.T) {
+	h := newTestCIBAHandler()
+
+	tests := []struct {
+		method   string
+		clientID string
+		secret   string
+		form     string
+		status   int
+		expected map[string]interface{}
+	}{
+		{http.MethodGet, "poll", "secret", "scope=openid&login_hint=alice", http.StatusMethodNotAllowed, map[string]interface{}{"error": "invalid_request"}},
+		{http.MethodPost, "poll", "wrong", "scope=openid&login_hint=alice", http.StatusUnauthorized, map[string]interface{}{"error": "invalid_client"}},
+		{http.MethodPost, "poll", "secret", "scope=profile&login_hint=alice", http.StatusBadRequest, map[string]interface{}{"error": "invalid_scope"}},
+		{http.MethodPost, "poll", "secret", "scope=openid&login_hint=alice&id_token_hint=foo", http.StatusBadRequest, map[string]interface{}{"error": "invalid_request"}},
+		{http.MethodPost, "poll", "secret", "scope=openid&login_hint=bob", http.StatusBadRequest, map[string]interface{}{"error": "unknown_user_id"}},
+		{http.MethodPost, "poll", "secret", "scope=openid&login_hint=carol", http.StatusBadRequest, map[string]interface{}{"error": "unknown_user_id", "error_description": "The user is unknown."}},
+		{http.MethodPost, "poll", "secret", "scope=openid&login_hint=alice", http.StatusOK, map[string]interface{}{"auth_req_id": "req-1", "expires_in": float64(120), "interval": float64(5)}},
+		{http.MethodPost, "push", "secret", "scope=openid&login_hint=alice", http.StatusBadRequest, map[string]interface{}{"error": "invalid_request"}},
+		{http.MethodPost, "push", "secret", "scope=openid&login_hint=alice&client_notification_token=abc", http.StatusOK, map[string]interface{}{"auth_req_id": "req-1", "expires_in": float64(120)}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.clientID+" "+tt.form, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, "/bc-authorize", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(tt.clientID, tt.secret)
+			w := httptest.NewRecorder()
+			h.BackchannelAuthorize(w, req)
+
+			var got map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if w.Code != tt.status || len(got) != len(tt.expected) {
+				t.Fatalf("BackchannelAuthorize(%s) => %d %v, expected %d %v", tt.form, w.Code, got, tt.status, tt.expected)
+			}
+			for k, v := range tt.expected {
+				if got[k] != v {
+					t.Errorf("BackchannelAuthorize(%s) => %s=%v, expected %v", tt.form, k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestBackchannelAuthorizeRateLimited(t *testing.T) {
+	h := newTestCIBAHandler()
+	h.RateLimiter = NewRateLimiter(nil)
+	h.RateLimiter.Client = RateLimit{Rate: 0.001, Burst: 1}
+
+	statuses := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, expected := range statuses {
+		req := httptest.NewRequest(http.MethodPost, "/bc-authorize", strings.NewReader("scope=openid&login_hint=alice"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("poll", "secret")
+		w := httptest.NewRecorder()
+		h.BackchannelAuthorize(w, req)
+
+		if w.Code != expected {
+			t.Errorf("BackchannelAuthorize #%d => %d, expected %d", i, w.Code, expected)
+		}
+	}
+}
+
+func TestCIBAGrant(t *testing.T) {
+	h := newTestCIBAHandler()
+
+	tests := []struct {
+		clientID  string
+		authReqID string
+		status    int
+		expected  string
+	}{
+		{"poll", "", http.StatusBadRequest, "invalid_request"},
+		{"poll", "pending", http.StatusBadRequest, "authorization_pending"},
+		{"poll", "slow", http.StatusBadRequest, "slow_down"},
+		{"poll", "slower", http.StatusBadRequest, "slow_down"},
+		{"poll", "expired", http.StatusBadRequest, "expired_token"},
+		{"poll", "unknown", http.StatusBadRequest, "invalid_grant"},
+		{"poll", "approved", http.StatusOK, ""},
+		{"push", "approved", http.StatusBadRequest, "unauthorized_client"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.clientID+" "+tt.authReqID, func(t *testing.T) {
+			t.Parallel()
+
+			form := url.Values{"grant_type": {"urn:openid:params:grant-type:ciba"}, "auth_req_id": {tt.authReqID}}
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(tt.clientID, "secret")
+			w := httptest.NewRecorder()
+			h.Token(w, req)
+
+			var got map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &got)
+			if w.Code != tt.status {
+				t.Errorf("Token(%s) => %d %v, expected %d", tt.authReqID, w.Code, got, tt.status)
+			}
+			if tt.expected != "" && got["error"] != tt.expected {
+				t.Errorf("Token(%s) => %v, expected %s", tt.authReqID, got["error"], tt.expected)
+			}
+			if tt.expected == "" && got["access_token"] != "token" {
+				t.Errorf("Token(%s) => %v, expected access token", tt.authReqID, got)
+			}
+		})
+	}
+}
+
+type testCIBAUserCodeService struct {
+	testCIBAService
+}
+
+func (testCIBAUserCodeService) BackchannelUserCodeParameterSupported() bool {
+	return true
+}
+
+func TestCIBAMetadata(t *testing.T) {
+	tests := []struct {
+		service  CIBAGrantTypeService
+		expected interface{}
+	}{
+		{testCIBAService{}, nil},
+		{testCIBAUserCodeService{}, true},
+	}
+
+	for _, tt := range tests {
+		h := NewHandler(testStorer{}, nil, NewCIBAGrantType(nil, tt.service))
+		w := httptest.NewRecorder()
+		h.Metadata(w, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server", nil))
+
+		var got map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &got)
+		if got["backchannel_token_delivery_modes_supported"] == nil || got["backchannel_user_code_parameter_supported"] != tt.expected {
+			t.Errorf("Metadata(%T) => %v, expected backchannel_user_code_parameter_supported %v", tt.service, got, tt.expected)
+		}
+	}
+}
+
+func TestCIBANotifier(t *testing.T) {
+	var got map[string]interface{}
+	var authorization string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		got = nil
+		json.NewDecoder(req.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := testCIBAClient{&testClient{id: "foo"}, CIBAPushMode, server.URL}
+	n := NewCIBANotifier(server.Client())
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		notify   func() error
+		expected map[string]interface{}
+	}{
+		{"Ping", func() error {
+			return n.Ping(ctx, client, "abc", "req-1")
+		}, map[string]interface{}{"auth_req_id": "req-1"}},
+		{"Push", func() error {
+			return n.Push(ctx, client, "abc", "req-1", &AccessResponse{AccessToken: "token", TokenType: "bearer", Info: map[string]interface{}{}})
+		}, map[string]interface{}{"auth_req_id": "req-1", "access_token": "token", "token_type": "bearer"}},
+		{"PushError", func() error {
+			return n.PushError(ctx, client, "abc", "req-1", NewError(ErrAccessDenied, "Denied."))
+		}, map[string]interface{}{"auth_req_id": "req-1", "error": "access_denied", "error_description": "Denied."}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.notify(); err != nil {
+			t.Fatalf("%s => %v, expected nil", tt.name, err)
+		}
+		if authorization != "Bearer abc" {
+			t.Errorf("%s => Authorization %s, expected Bearer abc", tt.name, authorization)
+		}
+		for k, v := range tt.expected {
+			if got[k] != v {
+				t.Errorf("%s => %s=%v, expected %v", tt.name, k, got[k], v)
+			}
+		}
+	}
+
+	status = http.StatusBadRequest
+	if err := n.Ping(ctx, client, "abc", "req-1"); err == nil {
+		t.Error("Ping => nil, expected error for failed notification")
+	}
+}
@@ -60,8 +60,10 @@ func (gt *clientGT) Grant(req *http.Request, client Client) (*AccessResponse, er
 		if gt.logger != nil {
 			gt.logger.Println(err)
 		}
-		return nil, ErrInvalidGrant
+		return nil, serviceError(err, ErrInvalidGrant)
 	}
 
+	emitEvent(req.Context(), &Event{Type: EventTokenIssued, Subject: client.Identifier()})
+
 	return access, nil
 }
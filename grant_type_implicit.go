@@ -3,6 +3,7 @@
 package oauth2
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 )
@@ -33,6 +34,7 @@ func NewImplicitGrantType(logger Log, service ImplicitGrantTypeService) GrantTyp
 
 var _ GrantType = (*implicitGT)(nil)
 var _ AuthorizeGrantType = (*implicitGT)(nil)
+var _ ResponseModeGrantType = (*implicitGT)(nil)
 
 type implicitGT struct {
 	logger  Log
@@ -47,13 +49,17 @@ func (gt *implicitGT) ResponseName() string {
 	return "token"
 }
 
+func (gt *implicitGT) DefaultResponseMode() string {
+	return ResponseModeFragment
+}
+
 func (gt *implicitGT) Respond(w http.ResponseWriter, req *http.Request, reqParams url.Values, client Client, redirectURI, state string) {
 	access, err := gt.service.ImplicitGrantTypeResponse(w, req, client, reqParams)
 	if err != nil {
 		if gt.logger != nil {
 			gt.logger.Println(err)
 		}
-		redirectWithError(w, req, redirectURI, state, ErrAccessDenied)
+		redirectWithError(w, req, redirectURI, state, serviceError(err, ErrAccessDenied))
 	}
 	if access == nil {
 		return
@@ -62,19 +68,35 @@ func (gt *implicitGT) Respond(w http.ResponseWriter, req *http.Request, reqParam
 	access.RefreshToken = ""
 	values := access.ToValues()
 
+	emitEvent(req.Context(), &Event{Type: EventAuthorizationIssued})
+
 	redirectWithValues(w, req, redirectURI, state, values)
 }
 
 func redirectWithError(w http.ResponseWriter, req *http.Request, redirectURI, state string, err error) {
+	emitRefused(req.Context(), errorCode(err))
+	responderFromContext(req.Context()).recordError(err)
+
 	values := url.Values{}
-	values.Set("error", err.Error())
+	values.Set("error", errorCode(err))
+
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Description != "" {
+			values.Set("error_description", e.Description)
+		}
+		if e.URI != "" {
+			values.Set("error_uri", e.URI)
+		}
+	}
 
 	redirectWithValues(w, req, redirectURI, state, values)
 }
 
 func redirectWithValues(w http.ResponseWriter, req *http.Request, redirectURI, state string, values url.Values) {
-	values.Set("state", state)
-	redirect := redirectURI + "#" + values.Encode()
+	if state != "" {
+		values.Set("state", state)
+	}
 
-	http.Redirect(w, req, redirect, http.StatusFound)
+	responderFromContext(req.Context()).redirect(w, req, redirectURI, values)
 }
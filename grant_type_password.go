@@ -26,7 +26,7 @@ const PasswordGrantType = "password"
 // Since this access token request utilizes the resource owner's
 // password, the authorization server MUST protect the endpoint against
 // brute force attacks (e.g., using rate-limitation or generating
-// alerts).
+// alerts). Handler.RateLimiter provides such protection.
 //
 // https://tools.ietf.org/html/rfc6749#section-4.3.2
 type PasswordGrantTypeService interface {
@@ -68,12 +68,14 @@ func (gt *passwordGT) Grant(req *http.Request, client Client) (*AccessResponse,
 		if gt.logger != nil {
 			gt.logger.Println(err)
 		}
-		return nil, ErrInvalidGrant
+		return nil, serviceError(err, ErrInvalidGrant)
 	}
 
 	if !issueRefreshToken {
 		access.RefreshToken = ""
 	}
 
+	emitEvent(req.Context(), &Event{Type: EventTokenIssued, Subject: username})
+
 	return access, nil
 }
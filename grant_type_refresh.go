@@ -4,7 +4,9 @@ package oauth2
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 )
 
 // RefreshGrantType is used for refreshing an access token.
@@ -29,22 +31,92 @@ const RefreshGrantType = "refresh"
 // identical to that of the refresh token included by the client in the
 // request.
 //
+// The client MAY request an access token for a subset of the resources
+// the refresh token was issued for. The requested resources returned by
+// ResourcesFromContext MUST be checked against the original grant.
+//
 // https://tools.ietf.org/html/rfc6749#section-6
+// https://tools.ietf.org/html/rfc8707#section-2.2
 type RefreshGrantTypeService interface {
 	RefreshGrantTypeResponse(ctx context.Context, client Client, refreshToken string) (*AccessResponse, error)
 }
 
+// RefreshTokenFamilies tracks families of rotated refresh tokens. A
+// family starts with a refresh token issued by another grant type and
+// holds every refresh token issued by rotating it. Only the latest
+// refresh token of a family is active.
+//
+// A refresh token is rotated in two steps: it is retired before the
+// service issues the new refresh token, which is then added to the
+// family. Concurrent requests with the same refresh token can thus never
+// both obtain tokens. If the service fails in between, the family has no
+// active refresh token and the client has to obtain a new grant.
+//
+// https://tools.ietf.org/html/draft-ietf-oauth-security-topics#section-4.14.2
+type RefreshTokenFamilies interface {
+	// StartRefreshTokenFamily starts a new family with the refresh token.
+	// Services issuing refresh tokens from other grant types MUST call it.
+	// The family MUST be identified by an opaque identifier, such as a
+	// hash of the refresh token, not by the refresh token itself.
+	StartRefreshTokenFamily(ctx context.Context, clientID, token string) error
+	// FindRefreshToken returns the family of the refresh token, the
+	// client it was issued to and whether it is the active one. It
+	// returns an empty family if the refresh token is unknown.
+	FindRefreshToken(ctx context.Context, token string) (family, clientID string, active bool, err error)
+	// RetireRefreshToken retires the active refresh token of the family.
+	// It MUST return ErrInvalidGrant if the refresh token is no longer
+	// active, and MUST do so atomically.
+	RetireRefreshToken(ctx context.Context, family, token string) error
+	// AddRefreshToken makes the new refresh token, issued in exchange for
+	// the retired old one, the active refresh token of the family.
+	AddRefreshToken(ctx context.Context, family, oldToken, newToken string) error
+	// RevokeRefreshTokenFamily revokes all refresh tokens of the family,
+	// as well as the access tokens issued with them.
+	RevokeRefreshTokenFamily(ctx context.Context, family string) error
+}
+
+// RefreshTokenReuse is the security event type for the reuse of a
+// retired refresh token.
+const RefreshTokenReuse = "refresh_token_reuse"
+
+// SecurityEvent is a detected security incident.
+type SecurityEvent struct {
+	Type     string
+	ClientID string
+	Family   string
+	Time     time.Time
+}
+
+// SecurityEventHook is notified of security events.
+type SecurityEventHook interface {
+	SecurityEvent(ctx context.Context, event *SecurityEvent)
+}
+
 // NewRefreshGrantType creates a new grant type.
 func NewRefreshGrantType(logger Log, service RefreshGrantTypeService) GrantType {
-	return &refreshGT{logger, service}
+	return &refreshGT{logger: logger, service: service}
+}
+
+// NewRotatingRefreshGrantType creates a new grant type rotating refresh
+// tokens. The service MUST issue a new refresh token with every access
+// response. If a retired refresh token is presented by the client it was
+// issued to, its whole family is revoked and a security event is logged
+// and passed to the hook, if any. Refresh tokens presented by another
+// client are rejected without revoking the family.
+//
+// https://tools.ietf.org/html/draft-ietf-oauth-security-topics#section-4.14.2
+func NewRotatingRefreshGrantType(logger Log, service RefreshGrantTypeService, families RefreshTokenFamilies, hook SecurityEventHook) GrantType {
+	return &refreshGT{logger, service, families, hook}
 }
 
 var _ GrantType = (*refreshGT)(nil)
 var _ TokenGrantType = (*refreshGT)(nil)
 
 type refreshGT struct {
-	logger  Log
-	service RefreshGrantTypeService
+	logger   Log
+	service  RefreshGrantTypeService
+	families RefreshTokenFamilies
+	hook     SecurityEventHook
 }
 
 func (gt *refreshGT) Identifier() string {
@@ -61,13 +133,103 @@ func (gt *refreshGT) Grant(req *http.Request, client Client) (*AccessResponse, e
 		return nil, ErrInvalidRequest
 	}
 
+	if gt.families != nil {
+		return gt.rotate(req, client, token)
+	}
+
 	access, err := gt.service.RefreshGrantTypeResponse(req.Context(), client, token)
 	if err != nil {
 		if gt.logger != nil {
 			gt.logger.Println(err)
 		}
+		return nil, serviceError(err, ErrInvalidGrant)
+	}
+
+	emitEvent(req.Context(), &Event{Type: EventTokenIssued})
+
+	return access, nil
+}
+
+func (gt *refreshGT) rotate(req *http.Request, client Client, token string) (*AccessResponse, error) {
+	ctx := req.Context()
+
+	family, clientID, active, err := gt.families.FindRefreshToken(ctx, token)
+	if err != nil {
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, ErrServerError
+	}
+	if family == "" {
+		return nil, ErrInvalidGrant
+	}
+	if clientID != client.Identifier() {
+		if gt.logger != nil {
+			gt.logger.Println("oauth2: refresh token of client", clientID, "presented by client", client.Identifier())
+		}
 		return nil, ErrInvalidGrant
 	}
+	if !active {
+		gt.reused(ctx, clientID, family)
+		return nil, ErrInvalidGrant
+	}
+
+	err = gt.families.RetireRefreshToken(ctx, family, token)
+	if errors.Is(err, ErrInvalidGrant) {
+		// A concurrent request rotated the refresh token first.
+		gt.reused(ctx, clientID, family)
+		return nil, ErrInvalidGrant
+	} else if err != nil {
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, ErrServerError
+	}
+
+	access, err := gt.service.RefreshGrantTypeResponse(ctx, client, token)
+	if err != nil {
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, serviceError(err, ErrInvalidGrant)
+	}
+	if access.RefreshToken == "" || access.RefreshToken == token {
+		if gt.logger != nil {
+			gt.logger.Println("oauth2: refresh token rotation requires a new refresh token")
+		}
+		return nil, ErrServerError
+	}
+
+	if err := gt.families.AddRefreshToken(ctx, family, token, access.RefreshToken); err != nil {
+		if gt.logger != nil {
+			gt.logger.Println(err)
+		}
+		return nil, ErrServerError
+	}
+
+	emitEvent(ctx, &Event{Type: EventTokenIssued})
 
 	return access, nil
 }
+
+// reused revokes the family of a reused refresh token, as either the
+// legitimate client or an attacker holds a stolen refresh token.
+func (gt *refreshGT) reused(ctx context.Context, clientID, family string) {
+	if err := gt.families.RevokeRefreshTokenFamily(ctx, family); err != nil && gt.logger != nil {
+		gt.logger.Println(err)
+	}
+
+	event := &SecurityEvent{
+		Type:     RefreshTokenReuse,
+		ClientID: clientID,
+		Family:   family,
+		Time:     time.Now(),
+	}
+	if gt.logger != nil {
+		gt.logger.Println("oauth2: refresh token reuse detected for client", event.ClientID, "in family", event.Family)
+	}
+	if gt.hook != nil {
+		gt.hook.SecurityEvent(ctx, event)
+	}
+	emitEvent(ctx, &Event{Type: EventRefreshTokenReuse, Time: event.Time, Error: ErrInvalidGrant.Error()})
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testRefreshService struct {
+	issued   int
+	families *testRefreshFamilies
+}
+
+func (s *testRefreshService) RefreshGrantTypeResponse(ctx context.Context, client Client, refreshToken string) (*AccessResponse, error) {
+	if s.families != nil && s.families.active[s.families.families[refreshToken]] == refreshToken {
+		return nil, errors.New("refresh token not retired before issuing tokens")
+	}
+	s.issued++
+	return &AccessResponse{
+		AccessToken:  "access" + strconv.Itoa(s.issued),
+		RefreshToken: "refresh" + strconv.Itoa(s.issued),
+		Info:         map[string]interface{}{},
+	}, nil
+}
+
+type testRefreshFamilies struct {
+	families map[string]string
+	clients  map[string]string
+	active   map[string]string
+	revoked  []string
+}
+
+func (f *testRefreshFamilies) StartRefreshTokenFamily(ctx context.Context, clientID, token string) error {
+	f.families[token] = token
+	f.clients[token] = clientID
+	f.active[token] = token
+	return nil
+}
+
+func (f *testRefreshFamilies) FindRefreshToken(ctx context.Context, token string) (string, string, bool, error) {
+	family := f.families[token]
+	return family, f.clients[family], family != "" && f.active[family] == token, nil
+}
+
+func (f *testRefreshFamilies) RetireRefreshToken(ctx context.Context, family, token string) error {
+	if f.active[family] != token {
+		return ErrInvalidGrant
+	}
+	delete(f.active, family)
+	return nil
+}
+
+func (f *testRefreshFamilies) AddRefreshToken(ctx context.Context, family, oldToken, newToken string) error {
+	f.families[newToken] = family
+	f.active[family] = newToken
+	return nil
+}
+
+func (f *testRefreshFamilies) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	f.revoked = append(f.revoked, family)
+	delete(f.active, family)
+	return nil
+}
+
+type testSecurityEventHook []*SecurityEvent
+
+func (h *testSecurityEventHook) SecurityEvent(ctx context.Context, event *SecurityEvent) {
+	*h = append(*h, event)
+}
+
+func TestRotatingRefreshGrantType(t *testing.T) {
+	families := &testRefreshFamilies{families: map[string]string{}, clients: map[string]string{}, active: map[string]string{}}
+	families.StartRefreshTokenFamily(context.Background(), "foo", "refresh0")
+	hook := &testSecurityEventHook{}
+	gt := NewRotatingRefreshGrantType(nil, &testRefreshService{families: families}, families, hook).(TokenGrantType)
+	client := &testClient{id: "foo"}
+
+	grantAs := func(client Client, token string) (*AccessResponse, error) {
+		body := url.Values{"refresh_token": {token}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return gt.Grant(req, client)
+	}
+	grant := func(token string) (*AccessResponse, error) {
+		return grantAs(client, token)
+	}
+
+	access, err := grant("refresh0")
+	if err != nil || access.RefreshToken != "refresh1" {
+		t.Fatalf("Grant(refresh0) => %v, %v, expected refresh1", access, err)
+	}
+
+	access, err = grant("refresh1")
+	if err != nil || access.RefreshToken != "refresh2" {
+		t.Fatalf("Grant(refresh1) => %v, %v, expected refresh2", access, err)
+	}
+
+	if _, err := grant("unknown"); err != ErrInvalidGrant {
+		t.Errorf("Grant(unknown) => %v, expected %v", err, ErrInvalidGrant)
+	}
+	if _, err := grantAs(&testClient{id: "bar"}, "refresh1"); err != ErrInvalidGrant {
+		t.Errorf("Grant(refresh1) by another client => %v, expected %v", err, ErrInvalidGrant)
+	}
+	if _, err := grantAs(&testClient{id: "bar"}, "refresh2"); err != ErrInvalidGrant {
+		t.Errorf("Grant(refresh2) by another client => %v, expected %v", err, ErrInvalidGrant)
+	}
+	if len(*hook) != 0 || len(families.revoked) != 0 {
+		t.Errorf("security events => %d, revoked families => %v, expected none", len(*hook), families.revoked)
+	}
+
+	if _, err := grant("refresh1"); err != ErrInvalidGrant {
+		t.Errorf("Grant(refresh1) reused => %v, expected %v", err, ErrInvalidGrant)
+	}
+	if len(families.revoked) != 1 || families.revoked[0] != "refresh0" {
+		t.Errorf("revoked families => %v, expected [refresh0]", families.revoked)
+	}
+	if len(*hook) != 1 || (*hook)[0].Type != RefreshTokenReuse || (*hook)[0].ClientID != "foo" {
+		t.Errorf("security events => %v, expected one %s event", *hook, RefreshTokenReuse)
+	}
+
+	if _, err := grant("refresh2"); err != ErrInvalidGrant {
+		t.Errorf("Grant(refresh2) after revocation => %v, expected %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestRefreshGrantTypeTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	store.SaveToken(ctx, &Token{Value: "refresh", Kind: RefreshTokenKind, ClientID: "foo"})
+	store.SaveToken(ctx, &Token{Value: "other", Kind: RefreshTokenKind, ClientID: "bar"})
+
+	storer := testStorer{"foo": &testClient{id: "foo", secret: "secret", grantTypes: []string{RefreshGrantType}}}
+	h := NewHandler(storer, nil, NewRefreshGrantType(nil, &testRefreshService{}))
+	h.TokenStore = store
+
+	post := func(endpoint string, handle http.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("foo", "secret")
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+	refresh := func(token string) *httptest.ResponseRecorder {
+		return post("/token", h.Token, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {token}})
+	}
+
+	if w := refresh("refresh"); w.Code != http.StatusOK {
+		t.Fatalf("Token(refresh) => %d %s, expected %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	post("/revoke", h.Revoke, url.Values{"token": {"refresh"}})
+
+	for _, token := range []string{"refresh", "other", "unknown"} {
+		if w := refresh(token); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+			t.Errorf("Token(%s) => %d %s, expected invalid_grant", token, w.Code, w.Body.String())
+		}
+	}
+}
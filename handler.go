@@ -3,10 +3,15 @@
 package oauth2
 
 import (
+	"context"
+	"errors"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 // Handler provides the oauth2 protocol endpoints:
@@ -22,6 +27,131 @@ import (
 //
 // https://tools.ietf.org/html/rfc6749#section-3
 type Handler struct {
+	// AuthorizationDetailsTypes holds the validators of the supported
+	// authorization details types, keyed by type. Requests containing
+	// authorization details are rejected if it is empty. Authorization
+	// details are accepted on the authorize and token endpoints; pushed
+	// authorization requests are not supported.
+	//
+	// https://tools.ietf.org/html/rfc9396
+	AuthorizationDetailsTypes map[string]AuthorizationDetailValidator
+
+	// Issuer is the issuer identifier of the authorization server. If
+	// set, it is returned in the iss parameter of every authorization
+	// response.
+	//
+	// https://tools.ietf.org/html/rfc9207
+	Issuer string
+
+	// ExtraMetadata holds additional authorization server metadata, such
+	// as the endpoint locations.
+	//
+	// https://tools.ietf.org/html/rfc8414#section-2
+	ExtraMetadata map[string]interface{}
+
+	// ResponseSigner signs JWT secured authorization responses. The jwt
+	// response modes are only supported if it and Issuer are set.
+	//
+	// https://openid.net/specs/oauth-v2-jarm.html
+	ResponseSigner JWTSigner
+
+	// ResponseEncrypter optionally encrypts JWT secured authorization
+	// responses.
+	ResponseEncrypter ResponseEncrypter
+
+	// Authenticator enables resource owner authentication on the
+	// authorize endpoint. Unauthenticated resource owners are redirected
+	// to LoginURL with a resume parameter identifying the parked
+	// authorization request. Once logged in, the login page sends them
+	// back to the authorize endpoint with the same resume parameter.
+	Authenticator Authenticator
+	LoginURL      string
+
+	// RequestStore parks authorization requests during login. It
+	// defaults to an in-memory store.
+	RequestStore RequestStore
+
+	// ConsentStore and ConsentPrompter enable consent management for
+	// every authorize grant type. It requires an Authenticator; if only
+	// part of it is set, authorization requests fail with server_error.
+	// The resource owner is only prompted if they have not yet consented
+	// to all requested scopes, or if the client requests prompt=consent.
+	ConsentStore    ConsentStore
+	ConsentPrompter ConsentPrompter
+
+	// TokenStore enables the revocation and introspection endpoints. If
+	// set, the refresh grant type only accepts refresh tokens found in
+	// it, so that revoked refresh tokens can no longer be used.
+	TokenStore TokenStore
+
+	// LogoutService enables the end session endpoint.
+	LogoutService LogoutService
+
+	// BackChannelLogout notifies the clients of ended sessions, if the
+	// LogoutService implements SessionClientLister. Failed deliveries are
+	// emitted as EventBackChannelLogoutFailed.
+	BackChannelLogout *BackChannelLogout
+
+	// FrontChannelLogout enables front-channel logout. Once the session
+	// ended, a page rendering the front-channel logout URIs of the
+	// clients of the session in iframes is shown, before continuing to
+	// the post logout redirection URI. It requires the LogoutService to
+	// implement SessionClientLister.
+	//
+	// https://openid.net/specs/openid-connect-frontchannel-1_0.html
+	FrontChannelLogout         bool
+	FrontChannelLogoutTemplate *template.Template
+
+	// AuthorizeErrorTemplate renders errors of the authorize endpoint
+	// that must not be redirected to the client, such as a missing or
+	// invalid client or redirection URI. It is executed with an
+	// AuthorizeErrorPage and defaults to DefaultAuthorizeErrorTemplate.
+	AuthorizeErrorTemplate *template.Template
+
+	// SecretVerifier verifies the secrets of clients implementing
+	// MultiSecretClient. It defaults to DefaultSecretVerifier.
+	SecretVerifier *SecretVerifier
+
+	// EventSink receives audit events of every protocol decision of the
+	// token and authorize endpoints, client authentication failures,
+	// revocations, and refusals of the revocation, introspection and end
+	// session endpoints.
+	EventSink EventSink
+
+	// Metrics collects metrics of the token and authorize endpoints and
+	// the grant types, if set.
+	Metrics *Metrics
+
+	// RequestID returns the identifier of the request for audit events.
+	// It defaults to the X-Request-Id header.
+	RequestID func(req *http.Request) string
+
+	// RemoteIP returns the IP of the request for audit events and rate
+	// limiting. It defaults to the host of RemoteAddr; set it when
+	// running behind a reverse proxy.
+	RemoteIP func(req *http.Request) string
+
+	// StrictRequests enables strict validation of token and authorize
+	// requests. Token requests must be POST requests with a form encoded
+	// body, authorization requests GET or POST requests. Parameters must
+	// not be included more than once, and request bodies must not exceed
+	// MaxRequestBodySize, which defaults to DefaultMaxRequestBodySize.
+	StrictRequests     bool
+	MaxRequestBodySize int64
+
+	// RedirectURIMatcher matches requested redirection URIs against the
+	// URIs registered by a RedirectURIsClient. It defaults to
+	// DefaultRedirectURIMatcher.
+	RedirectURIMatcher *RedirectURIMatcher
+
+	// Tracer opens spans around client lookup, client authentication,
+	// the grant types and response writing. It defaults to NoopTracer.
+	Tracer Tracer
+
+	// RateLimiter protects the token and authorize endpoints against
+	// brute force attacks. It is disabled if nil.
+	RateLimiter *RateLimiter
+
 	storer       Storer
 	logger       Log
 	tokenGTs     map[string]TokenGrantType
@@ -47,43 +177,104 @@ func NewHandler(storer Storer, logger Log, gts ...GrantType) *Handler {
 	}
 
 	return &Handler{
-		storer:       storer,
-		logger:       logger,
-		tokenGTs:     tokenGTs,
-		authorizeGTs: authorizeGTs,
+		RequestStore:   NewMemoryRequestStore(10 * time.Minute),
+		SecretVerifier: DefaultSecretVerifier,
+		storer:         storer,
+		logger:         logger,
+		tokenGTs:       tokenGTs,
+		authorizeGTs:   authorizeGTs,
 	}
 }
 
-func (h *Handler) clientFromRequest(req *http.Request, grantType GrantType) (Client, error) {
-	clientID, clientSecret, ok := req.BasicAuth()
-	if !ok {
-		clientID = req.FormValue("client_id")
-		clientSecret = ""
+func (h *Handler) clientFromRequest(req *http.Request, grantType GrantType) (Client, *http.Request, error) {
+	client, req, err := h.authenticateClient(req)
+	if err != nil {
+		return nil, req, err
 	}
 
+	if !client.IsAllowedGrantType(grantType.Identifier()) {
+		return nil, req, ErrUnauthorizedClient
+	}
+
+	setEventClient(req.Context(), client)
+
+	return client, req, nil
+}
+
+// authenticateClient returns the client of the request. If the client
+// authenticated with one of several secrets, the returned request
+// carries it in its context.
+func (h *Handler) authenticateClient(req *http.Request) (Client, *http.Request, error) {
+	clientID, clientSecret := clientCredentials(req)
 	if clientID == "" {
-		return nil, ErrInvalidRequest
+		return nil, req, ErrInvalidRequest
+	}
+
+	ctx, span := h.startSpan(req.Context(), "oauth2.AuthenticateClient", SpanAttribute{AttributeClientID, clientID})
+	client, secret, err := h.verifyClient(ctx, clientID, clientSecret)
+	endSpan(span, err)
+
+	if errors.Is(err, ErrInvalidClient) {
+		emitEvent(req.Context(), &Event{Type: EventClientAuthenticationFailed, Error: err.Error()})
+	}
+	if err != nil {
+		return nil, req, err
+	}
+
+	if secret != nil {
+		req = withContextValue(req, clientSecretKey, secret)
 	}
 
-	client, err := h.storer.FindClient(req.Context(), clientID)
+	return client, req, nil
+}
+
+// verifyClient finds the client and verifies its secret. It returns the
+// matching secret of a MultiSecretClient.
+func (h *Handler) verifyClient(ctx context.Context, clientID, clientSecret string) (Client, *ClientSecret, error) {
+	ctx, span := h.startSpan(ctx, "oauth2.FindClient", SpanAttribute{AttributeClientID, clientID})
+	client, err := h.storer.FindClient(ctx, clientID)
+	endSpan(span, err)
 	if err != nil {
-		return nil, ErrServerError
+		return nil, nil, ErrServerError
 	}
 	if client == nil {
-		return nil, ErrInvalidClient
+		return nil, nil, ErrInvalidClient
 	}
 
-	if client.IsConfidential() {
-		if clientSecret == "" || !client.Authenticate(clientSecret) {
-			return nil, ErrInvalidClient
+	if !client.IsConfidential() {
+		return client, nil, nil
+	}
+	if clientSecret == "" {
+		return nil, nil, ErrInvalidClient
+	}
+
+	if msc, ok := client.(MultiSecretClient); ok {
+		verifier := h.SecretVerifier
+		if verifier == nil {
+			verifier = DefaultSecretVerifier
 		}
+		secret := verifier.Verify(msc.ClientSecrets(), clientSecret, time.Now())
+		if secret == nil {
+			return nil, nil, ErrInvalidClient
+		}
+		return client, secret, nil
 	}
 
-	if !client.IsAllowedGrantType(grantType.Identifier()) {
-		return nil, ErrUnauthorizedClient
+	if !client.Authenticate(clientSecret) {
+		return nil, nil, ErrInvalidClient
 	}
 
-	return client, nil
+	return client, nil, nil
+}
+
+// clientCredentials returns the client identifier and secret of the
+// request. The secret is only accepted via HTTP Basic authentication.
+func clientCredentials(req *http.Request) (string, string) {
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		return req.FormValue("client_id"), ""
+	}
+	return clientID, clientSecret
 }
 
 // Token is used by the client to obtain an access token by
@@ -93,36 +284,103 @@ func (h *Handler) clientFromRequest(req *http.Request, grantType GrantType) (Cli
 //
 // https://tools.ietf.org/html/rfc6749#section-3.2
 func (h *Handler) Token(w http.ResponseWriter, req *http.Request) {
+	req = h.withEvents(req, EventTokenRefused)
+	if h.Metrics != nil {
+		defer h.Metrics.observe("token", eventsFromContext(req.Context()), time.Now())
+	}
+
+	if h.StrictRequests && !h.validateTokenRequest(w, req) {
+		return
+	}
+	describeRequest(req)
+
 	grantName := req.PostFormValue("grant_type")
 	if grantName == "" {
-		writeError(w, h.logger, http.StatusBadRequest, ErrInvalidRequest, "")
+		h.refuse(w, req, http.StatusBadRequest, ErrInvalidRequest, "")
 		return
 	}
 
 	grantType, ok := h.tokenGTs[grantName]
 	if !ok {
-		writeError(w, h.logger, http.StatusBadRequest, ErrUnsupportedGrantType, "")
+		h.refuse(w, req, http.StatusBadRequest, ErrUnsupportedGrantType, "")
+		return
+	}
+
+	var username string
+	if grantType.Identifier() == PasswordGrantType {
+		username = req.PostFormValue("username")
+	}
+	describeEvents(req.Context(), grantType.Identifier(), username)
+
+	limitKeys := h.rateLimitKeys(req, username)
+	if !h.limitRequest(w, req, limitKeys, "") {
 		return
 	}
 
-	client, err := h.clientFromRequest(req, grantType)
+	client, req, err := h.clientFromRequest(req, grantType)
 	if err != nil {
-		if err == ErrInvalidClient {
+		if errors.Is(err, ErrInvalidClient) {
+			h.recordAuthentication(req.Context(), limitKeys.clientLockout, false)
 			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
-			writeError(w, h.logger, http.StatusUnauthorized, err, "")
+			h.refuse(w, req, http.StatusUnauthorized, err, "")
+			return
+		} else if errors.Is(err, ErrServerError) {
+			h.refuse(w, req, http.StatusInternalServerError, err, "")
 			return
-		} else if err == ErrServerError {
-			writeError(w, h.logger, http.StatusInternalServerError, err, "")
 		}
-		writeError(w, h.logger, http.StatusBadRequest, err, "")
+		h.refuse(w, req, http.StatusBadRequest, err, "")
 		return
 	}
 
-	access, err := grantType.Grant(req, client)
+	details, err := parseAuthorizationDetails(req.Context(), req.PostFormValue("authorization_details"), client, h.AuthorizationDetailsTypes)
 	if err != nil {
-		writeError(w, h.logger, http.StatusBadRequest, err, "")
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+	if details != nil {
+		req = withContextValue(req, authorizationDetailsKey, details)
+	}
+
+	resources := req.PostForm["resource"]
+	if err := validateResources(resources, client); err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
 		return
 	}
+	if len(resources) > 0 {
+		req = withContextValue(req, resourcesKey, resources)
+	}
+
+	if grantType.Identifier() == RefreshGrantType && h.TokenStore != nil {
+		if err := h.checkRefreshToken(req.Context(), req.PostFormValue("refresh_token"), client); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrServerError) {
+				status = http.StatusInternalServerError
+			}
+			h.refuse(w, req, status, err, "")
+			return
+		}
+	}
+
+	h.recordAuthentication(req.Context(), limitKeys.clientLockout, true)
+
+	ctx, span := h.startSpan(req.Context(), "oauth2.Grant",
+		SpanAttribute{AttributeGrantType, grantType.Identifier()},
+		SpanAttribute{AttributeClientID, client.Identifier()},
+	)
+	access, err := grantType.Grant(req.WithContext(ctx), client)
+	endSpan(span, err)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGrant) && username != "" {
+			h.recordAuthentication(req.Context(), limitKeys.usernameLockout, false)
+		}
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	h.recordAuthentication(req.Context(), limitKeys.usernameLockout, true)
+
+	_, span = h.startSpan(req.Context(), "oauth2.WriteResponse", SpanAttribute{AttributeEndpoint, "token"})
+	defer span.End()
 
 	writeJSON(w, h.logger, http.StatusOK, access.ToMap(), map[string]string{
 		"Cache-Control": "no-store",
@@ -130,6 +388,27 @@ func (h *Handler) Token(w http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// checkRefreshToken rejects refresh tokens unknown to the TokenStore,
+// e.g. revoked ones, and refresh tokens issued to another client.
+// Retired refresh tokens pass, so that rotation detects their reuse.
+func (h *Handler) checkRefreshToken(ctx context.Context, value string, client Client) error {
+	if value == "" {
+		return nil
+	}
+
+	token, err := h.TokenStore.FindToken(ctx, value)
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Println(err)
+		}
+		return ErrServerError
+	}
+	if token == nil || token.Kind != RefreshTokenKind || token.ClientID != client.Identifier() {
+		return ErrInvalidGrant
+	}
+	return nil
+}
+
 // Authorize is used to interact with the resource
 // owner and obtain an authorization grant. The authorization server
 // MUST first verify the identity of the resource owner. The way in
@@ -139,43 +418,191 @@ func (h *Handler) Token(w http.ResponseWriter, req *http.Request) {
 //
 // https://tools.ietf.org/html/rfc6749#section-3.1
 func (h *Handler) Authorize(w http.ResponseWriter, req *http.Request) {
-	responseName := req.FormValue("response_type")
-	redirectURI := req.FormValue("redirect_uri")
-	state := req.FormValue("state")
-	if responseName == "" || redirectURI == "" || state == "" {
-		writeError(w, h.logger, http.StatusBadRequest, ErrInvalidRequest, state)
+	req = h.withEvents(req, EventAuthorizationRefused)
+	if h.Metrics != nil {
+		defer h.Metrics.observe("authorize", eventsFromContext(req.Context()), time.Now())
+	}
+
+	if h.StrictRequests && !h.validateAuthorizeRequest(w, req) {
+		return
+	}
+
+	parkedAt, err := h.resumeRequest(req)
+	if errors.Is(err, ErrServerError) {
+		h.refusePage(w, req, http.StatusInternalServerError, err)
+		return
+	} else if err != nil {
+		h.refusePage(w, req, http.StatusBadRequest, err)
 		return
 	}
+	describeRequest(req)
+
+	responseName := req.FormValue("response_type")
+	state := req.FormValue("state")
 
 	grantType, ok := h.authorizeGTs[responseName]
-	if !ok {
-		writeError(w, h.logger, http.StatusBadRequest, ErrUnsupportedResponseType, state)
+	if ok {
+		describeEvents(req.Context(), grantType.Identifier(), "")
+	}
+
+	limitKeys := h.rateLimitKeys(req, "")
+	if !h.limitRequest(w, req, limitKeys, state) {
 		return
 	}
 
-	client, err := h.clientFromRequest(req, grantType)
+	// Errors of the client and the redirection URI are shown to the
+	// resource owner, as the user-agent must not be redirected to an
+	// unverified redirection URI.
+	//
+	// https://tools.ietf.org/html/rfc6749#section-4.1.2.1
+	client, req, err := h.authenticateClient(req)
 	if err != nil {
-		if err == ErrInvalidClient {
-			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
-			writeError(w, h.logger, http.StatusUnauthorized, err, state)
+		if errors.Is(err, ErrInvalidClient) {
+			h.recordAuthentication(req.Context(), limitKeys.clientLockout, false)
+			h.refusePage(w, req, http.StatusUnauthorized, err)
+			return
+		} else if errors.Is(err, ErrServerError) {
+			h.refusePage(w, req, http.StatusInternalServerError, err)
 			return
-		} else if err == ErrServerError {
-			writeError(w, h.logger, http.StatusInternalServerError, err, state)
 		}
-		writeError(w, h.logger, http.StatusBadRequest, err, state)
+		if errors.Is(err, ErrInvalidRequest) {
+			err = NewError(err, "The client_id parameter is missing.")
+		}
+		h.refusePage(w, req, http.StatusBadRequest, err)
+		return
+	}
+
+	redirectURI, err := h.resolveRedirectURI(client, req.FormValue("redirect_uri"))
+	if err != nil {
+		h.refusePage(w, req, http.StatusBadRequest, err)
+		return
+	}
+
+	// From here on, errors are returned to the client by redirecting to
+	// the verified redirection URI.
+	setEventClient(req.Context(), client)
+
+	responseMode := ResponseModeQuery
+	if ok {
+		responseMode = defaultResponseMode(grantType)
+	}
+	req = withContextValue(req, responderKey, h.newResponder(responseMode, client))
+
+	if responseName == "" {
+		redirectWithError(w, req, redirectURI, state, NewError(ErrInvalidRequest, "The response_type parameter is missing."))
+		return
+	}
+	if !ok {
+		redirectWithError(w, req, redirectURI, state, ErrUnsupportedResponseType)
+		return
+	}
+	if state == "" && requiresState(client) {
+		redirectWithError(w, req, redirectURI, state, NewError(ErrInvalidRequest, "The state parameter is missing."))
 		return
 	}
 
-	if !client.IsAllowedRedirectURI(redirectURI) {
-		writeError(w, h.logger, http.StatusBadRequest, ErrInvalidRequest, state)
+	if !client.IsAllowedGrantType(grantType.Identifier()) {
+		redirectWithError(w, req, redirectURI, state, ErrUnauthorizedClient)
+		return
+	}
+
+	responseMode, err = resolveResponseMode(req.FormValue("response_mode"), grantType, h.ResponseSigner, h.Issuer)
+	if err != nil {
+		redirectWithError(w, req, redirectURI, state, err)
+		return
+	}
+	req = withContextValue(req, responderKey, h.newResponder(responseMode, client))
+
+	rawDetails := req.FormValue("authorization_details")
+	details, err := parseAuthorizationDetails(req.Context(), rawDetails, client, h.AuthorizationDetailsTypes)
+	if err != nil {
+		redirectWithError(w, req, redirectURI, state, err)
+		return
+	}
+
+	resources := req.Form["resource"]
+	if err := validateResources(resources, client); err != nil {
+		redirectWithError(w, req, redirectURI, state, err)
 		return
 	}
 
 	values := url.Values{}
 	values.Set("response_type", responseName)
 	values.Set("client_id", client.Identifier())
-	values.Set("redirect_uri", redirectURI)
-	values.Set("state", state)
+	if v := req.FormValue("redirect_uri"); v != "" {
+		values.Set("redirect_uri", v)
+	}
+	if state != "" {
+		values.Set("state", state)
+	}
+
+	if responseMode != defaultResponseMode(grantType) {
+		values.Set("response_mode", responseMode)
+	}
+
+	if details != nil {
+		values.Set("authorization_details", rawDetails)
+		req = withContextValue(req, authorizationDetailsKey, details)
+	}
+
+	if len(resources) > 0 {
+		values["resource"] = resources
+		req = withContextValue(req, resourcesKey, resources)
+	}
 
-	grantType.Respond(w, req, values, client, redirectURI, state)
+	scope := req.FormValue("scope")
+	if scope != "" {
+		values.Set("scope", scope)
+	}
+	prompt := strings.Fields(req.FormValue("prompt"))
+	if h.Authenticator != nil {
+		session := h.authenticate(w, req, redirectURI, state, prompt, parkedAt)
+		if session == nil {
+			return
+		}
+		req = withContextValue(req, sessionKey, session)
+
+		if !parkedAt.IsZero() {
+			prompt = removeLoginPrompts(prompt)
+		}
+	}
+	if len(prompt) > 0 {
+		values.Set("prompt", strings.Join(prompt, " "))
+	}
+
+	if h.ConsentStore != nil || h.ConsentPrompter != nil {
+		// Consent management must not be skipped silently if it is only
+		// partially configured.
+		if h.Authenticator == nil || h.ConsentStore == nil || h.ConsentPrompter == nil {
+			h.logger.Println(errConsentConfig)
+			redirectWithError(w, req, redirectURI, state, ErrServerError)
+			return
+		}
+
+		granted, ok := h.consent(w, req, values, client, redirectURI, parseScopes(scope), prompt)
+		if !ok {
+			return
+		}
+		req = withContextValue(req, grantedScopesKey, granted)
+	}
+
+	ctx, span := h.startSpan(req.Context(), "oauth2.Respond",
+		SpanAttribute{AttributeGrantType, grantType.Identifier()},
+		SpanAttribute{AttributeClientID, client.Identifier()},
+	)
+	defer span.End()
+	// Grant types respond themselves, so errors they redirect with are
+	// recorded on the span by redirectWithError.
+	responderFromContext(ctx).span = span
+
+	grantType.Respond(w, req.WithContext(ctx), values, client, redirectURI, state)
+}
+
+// requiresState reports whether authorization requests of the client
+// must include the state parameter.
+func requiresState(client Client) bool {
+	if c, ok := client.(StatePolicyClient); ok {
+		return c.RequiresState()
+	}
+	return true
 }
@@ -0,0 +1,105 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Introspect is used by protected resources to query the state of a
+// token. Protected resources authenticate as confidential clients. It
+// responds with 404 Not Found if the handler has no TokenStore.
+//
+// https://tools.ietf.org/html/rfc7662#section-2
+func (h *Handler) Introspect(w http.ResponseWriter, req *http.Request) {
+	if h.TokenStore == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	req = h.withEvents(req, EventIntrospectionRefused)
+
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.refuse(w, req, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+		return
+	}
+
+	value := req.PostFormValue("token")
+	if value == "" {
+		h.refuse(w, req, http.StatusBadRequest, ErrInvalidRequest, "")
+		return
+	}
+
+	describeRequest(req)
+
+	client, req, err := h.authenticateClient(req)
+	if err == nil && !client.IsConfidential() {
+		err = ErrInvalidClient
+	}
+	if errors.Is(err, ErrInvalidClient) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		h.refuse(w, req, http.StatusUnauthorized, err, "")
+		return
+	} else if errors.Is(err, ErrServerError) {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	} else if err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	token, err := h.TokenStore.FindToken(req.Context(), value)
+	if err != nil {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	}
+
+	writeJSON(w, h.logger, http.StatusOK, h.introspection(token), map[string]string{
+		"Cache-Control": "no-store",
+		"Pragma":        "no-cache",
+	})
+}
+
+// introspection returns the introspection response for the token. Only
+// access and refresh tokens are introspectable, authorization and device
+// codes are reported as inactive.
+//
+// https://tools.ietf.org/html/rfc7662#section-2.2
+func (h *Handler) introspection(token *Token) map[string]interface{} {
+	if token == nil || !token.IsActive(time.Now()) {
+		return map[string]interface{}{"active": false}
+	}
+	if token.Kind != AccessTokenKind && token.Kind != RefreshTokenKind {
+		return map[string]interface{}{"active": false}
+	}
+
+	m := map[string]interface{}{
+		"active":    true,
+		"client_id": token.ClientID,
+		"iat":       token.IssuedAt.Unix(),
+	}
+	if h.Issuer != "" {
+		m["iss"] = h.Issuer
+	}
+	if token.Subject != "" {
+		m["sub"] = token.Subject
+	}
+	if len(token.Scopes) > 0 {
+		m["scope"] = strings.Join(token.Scopes, " ")
+	}
+	if len(token.Audience) > 0 {
+		m["aud"] = token.Audience
+	}
+	if !token.ExpiresAt.IsZero() {
+		m["exp"] = token.ExpiresAt.Unix()
+	}
+	if len(token.AuthorizationDetails) > 0 {
+		m["authorization_details"] = token.AuthorizationDetails
+	}
+
+	return m
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	now := time.Now()
+	for _, token := range []*Token{
+		{Value: "access", Kind: AccessTokenKind, ClientID: "foo", Subject: "alice", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{Value: "refresh", Kind: RefreshTokenKind, ClientID: "foo", Subject: "alice", IssuedAt: now},
+		{Value: "code", Kind: AuthorizationCodeKind, ClientID: "foo", Subject: "alice", IssuedAt: now, ExpiresAt: now.Add(time.Minute)},
+		{Value: "device", Kind: DeviceCodeKind, ClientID: "foo", IssuedAt: now, ExpiresAt: now.Add(time.Minute)},
+	} {
+		store.SaveToken(ctx, token)
+	}
+
+	storer := testStorer{"rs": &testClient{id: "rs", secret: "secret"}}
+	h := NewHandler(storer, nil)
+	h.TokenStore = store
+
+	tests := []struct {
+		token    string
+		expected bool
+	}{
+		{"access", true},
+		{"refresh", true},
+		{"code", false},
+		{"device", false},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.token, func(t *testing.T) {
+			t.Parallel()
+
+			form := url.Values{"token": {tt.token}}
+			req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("rs", "secret")
+			w := httptest.NewRecorder()
+			h.Introspect(w, req)
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("Introspect(%s) => %d %s, expected %d", tt.token, w.Code, w.Body.String(), http.StatusOK)
+			}
+			if got["active"] != tt.expected {
+				t.Errorf("Introspect(%s) => %v, expected active %t", tt.token, got, tt.expected)
+			}
+			if !tt.expected && len(got) != 1 {
+				t.Errorf("Introspect(%s) => %v, expected only active", tt.token, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// JWTSigner signs JSON Web Tokens using the JWS compact serialization.
+//
+// https://tools.ietf.org/html/rfc7515
+type JWTSigner interface {
+	// Algorithm returns the JWS "alg" header parameter value.
+	Algorithm() string
+	// KeyID returns the JWS "kid" header parameter value, if any.
+	KeyID() string
+	// Sign returns the signature of the JWS signing input.
+	Sign(signingInput []byte) ([]byte, error)
+}
+
+// NewHMACSigner creates a new signer using HMAC with SHA-256 (HS256).
+func NewHMACSigner(keyID string, key []byte) JWTSigner {
+	return &hmacSigner{keyID, key}
+}
+
+// NewRSASigner creates a new signer using RSASSA-PKCS1-v1_5 with
+// SHA-256 (RS256).
+func NewRSASigner(keyID string, key *rsa.PrivateKey) JWTSigner {
+	return &rsaSigner{keyID, key}
+}
+
+// NewECDSASigner creates a new signer using ECDSA with the P-256 curve
+// and SHA-256 (ES256).
+func NewECDSASigner(keyID string, key *ecdsa.PrivateKey) (JWTSigner, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("oauth2: ES256 requires a P-256 key")
+	}
+	return &ecdsaSigner{keyID, key}, nil
+}
+
+type hmacSigner struct {
+	keyID string
+	key   []byte
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return "HS256"
+}
+
+func (s *hmacSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *hmacSigner) Sign(signingInput []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(signingInput)
+	return mac.Sum(nil), nil
+}
+
+type rsaSigner struct {
+	keyID string
+	key   *rsa.PrivateKey
+}
+
+func (s *rsaSigner) Algorithm() string {
+	return "RS256"
+}
+
+func (s *rsaSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *rsaSigner) Sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+}
+
+type ecdsaSigner struct {
+	keyID string
+	key   *ecdsa.PrivateKey
+}
+
+func (s *ecdsaSigner) Algorithm() string {
+	return "ES256"
+}
+
+func (s *ecdsaSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *ecdsaSigner) Sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// JWS uses the fixed size concatenation of R and S.
+	//
+	// https://tools.ietf.org/html/rfc7518#section-3.4
+	rb, sb := r.Bytes(), ss.Bytes()
+	sig := make([]byte, 64)
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	return sig, nil
+}
+
+func signJWT(signer JWTSigner, claims map[string]interface{}) (string, error) {
+	header := map[string]string{
+		"alg": signer.Algorithm(),
+		"typ": "JWT",
+	}
+	if kid := signer.KeyID(); kid != "" {
+		header["kid"] = kid
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(headerJSON) + "." + enc.EncodeToString(claimsJSON)
+
+	sig, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
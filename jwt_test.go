@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSignJWT(t *testing.T) {
+	hmacKey := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSigner, err := NewECDSASigner("ec", ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		signer JWTSigner
+		verify func(input, sig []byte) bool
+	}{
+		{NewHMACSigner("hmac", hmacKey), func(input, sig []byte) bool {
+			mac := hmac.New(sha256.New, hmacKey)
+			mac.Write(input)
+			return hmac.Equal(sig, mac.Sum(nil))
+		}},
+		{NewRSASigner("rsa", rsaKey), func(input, sig []byte) bool {
+			digest := sha256.Sum256(input)
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+		}},
+		{ecSigner, func(input, sig []byte) bool {
+			digest := sha256.Sum256(input)
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.signer.Algorithm(), func(t *testing.T) {
+			t.Parallel()
+			token, err := signJWT(tt.signer, map[string]interface{}{"iss": "https://as.example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parts := strings.Split(token, ".")
+			if len(parts) != 3 {
+				t.Fatalf("signJWT => %s, expected three parts", token)
+			}
+
+			var header map[string]string
+			headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			if err := json.Unmarshal(headerJSON, &header); err != nil {
+				t.Fatal(err)
+			}
+			if header["alg"] != tt.signer.Algorithm() || header["kid"] != tt.signer.KeyID() {
+				t.Errorf("signJWT header => %v, expected alg %s and kid %s", header, tt.signer.Algorithm(), tt.signer.KeyID())
+			}
+
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if !tt.verify([]byte(parts[0]+"."+parts[1]), sig) {
+				t.Errorf("signJWT => invalid signature")
+			}
+		})
+	}
+}
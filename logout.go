@@ -0,0 +1,217 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// PostLogoutRedirectClient is a client that registered post logout
+// redirection URIs. Logout requests with a post_logout_redirect_uri are
+// rejected for clients not implementing it.
+//
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#ClientMetadata
+type PostLogoutRedirectClient interface {
+	Client
+	IsAllowedPostLogoutRedirectURI(uri string) bool
+}
+
+// IDTokenHint holds the verified claims of an id_token_hint.
+type IDTokenHint struct {
+	// Subject is the sub claim.
+	Subject string
+	// ClientID is the client the ID token was issued to.
+	ClientID string
+	// SessionID is the sid claim, if any.
+	SessionID string
+}
+
+// LogoutRequest is a validated RP-initiated logout request.
+type LogoutRequest struct {
+	// Client is the client requesting the logout, if known.
+	Client Client
+	// Session is the session of the resource owner, if any.
+	Session *Session
+	// IDTokenHint holds the verified id_token_hint, if any.
+	IDTokenHint *IDTokenHint
+	// PostLogoutRedirectURI is the verified post logout redirection URI, if any.
+	PostLogoutRedirectURI string
+	// State is the opaque value to return to the client.
+	State string
+}
+
+// LogoutService ends the sessions of resource owners.
+//
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+type LogoutService interface {
+	// VerifyIDTokenHint verifies an ID token previously issued by the
+	// authorization server. It SHOULD accept expired ID tokens.
+	VerifyIDTokenHint(ctx context.Context, hint string) (*IDTokenHint, error)
+
+	// ConfirmLogout asks the resource owner whether they want to log
+	// out. It either renders a confirmation page, in which case it
+	// returns false, or processes the resource owner's decision and
+	// returns true if they confirmed.
+	ConfirmLogout(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) (bool, error)
+
+	// EndSession ends the session of the resource owner.
+	EndSession(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) error
+
+	// LoggedOut renders the page shown after logout if there is no post
+	// logout redirection URI.
+	LoggedOut(w http.ResponseWriter, req *http.Request, logout *LogoutRequest)
+}
+
+// EndSession is used by the client to request that the resource owner
+// be logged out at the authorization server. It responds with 404 Not
+// Found if the handler has no LogoutService.
+//
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+func (h *Handler) EndSession(w http.ResponseWriter, req *http.Request) {
+	if h.LogoutService == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	req = h.withEvents(req, EventLogoutRefused)
+	describeRequest(req)
+
+	logout, err := h.logoutRequest(req)
+	if errors.Is(err, ErrServerError) {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	} else if err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	confirmed, err := h.LogoutService.ConfirmLogout(w, req, logout)
+	if err != nil {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	}
+	if !confirmed {
+		return
+	}
+
+	// The clients of the session are looked up before it ends, as
+	// ending it may forget them.
+	clients, err := h.sessionClients(req.Context(), logout.Session)
+	if err != nil {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	}
+
+	if err := h.LogoutService.EndSession(w, req, logout); err != nil {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	}
+
+	if h.BackChannelLogout != nil && len(clients) > 0 {
+		// Deliveries are retried with backoff, so they must not hold
+		// up the resource owner.
+		go h.dispatchBackChannelLogout(context.WithoutCancel(req.Context()), logout.Session, clients)
+	}
+
+	if h.FrontChannelLogout {
+		if frames := frontChannelLogoutURIs(h.Issuer, logout.Session, clients); len(frames) > 0 {
+			h.writeFrontChannelLogout(w, frames, postLogoutRedirect(logout))
+			return
+		}
+	}
+
+	h.finishLogout(w, req, logout)
+}
+
+func (h *Handler) logoutRequest(req *http.Request) (*LogoutRequest, error) {
+	logout := &LogoutRequest{
+		State: req.FormValue("state"),
+	}
+
+	clientID := req.FormValue("client_id")
+
+	if hint := req.FormValue("id_token_hint"); hint != "" {
+		idToken, err := h.LogoutService.VerifyIDTokenHint(req.Context(), hint)
+		if err != nil {
+			h.logger.Println(err)
+			return nil, ErrInvalidRequest
+		}
+		if clientID != "" && clientID != idToken.ClientID {
+			return nil, ErrInvalidRequest
+		}
+		clientID = idToken.ClientID
+		logout.IDTokenHint = idToken
+	}
+
+	if clientID != "" {
+		client, err := h.storer.FindClient(req.Context(), clientID)
+		if err != nil {
+			h.logger.Println(err)
+			return nil, ErrServerError
+		}
+		if client == nil {
+			return nil, ErrInvalidClient
+		}
+		logout.Client = client
+	}
+
+	if uri := req.FormValue("post_logout_redirect_uri"); uri != "" {
+		client, ok := logout.Client.(PostLogoutRedirectClient)
+		if !ok || !client.IsAllowedPostLogoutRedirectURI(uri) {
+			return nil, ErrInvalidRequest
+		}
+		if _, err := url.Parse(uri); err != nil {
+			return nil, ErrInvalidRequest
+		}
+		logout.PostLogoutRedirectURI = uri
+	}
+
+	if h.Authenticator != nil {
+		session, err := h.Authenticator.Session(req)
+		if err != nil {
+			h.logger.Println(err)
+			return nil, ErrServerError
+		}
+		logout.Session = session
+	}
+
+	return logout, nil
+}
+
+func (h *Handler) sessionClients(ctx context.Context, session *Session) ([]Client, error) {
+	lister, ok := h.LogoutService.(SessionClientLister)
+	if !ok || session == nil {
+		return nil, nil
+	}
+	return lister.SessionClients(ctx, session)
+}
+
+// finishLogout sends the resource owner back to the client, or renders
+// the logged out page.
+func (h *Handler) finishLogout(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) {
+	if logout.PostLogoutRedirectURI == "" {
+		h.LogoutService.LoggedOut(w, req, logout)
+		return
+	}
+
+	http.Redirect(w, req, postLogoutRedirect(logout), http.StatusFound)
+}
+
+// postLogoutRedirect returns the post logout redirection URI with the
+// state added, or an empty string if there is none.
+func postLogoutRedirect(logout *LogoutRequest) string {
+	if logout.PostLogoutRedirectURI == "" || logout.State == "" {
+		return logout.PostLogoutRedirectURI
+	}
+
+	// The URI has been parsed by logoutRequest.
+	u, _ := url.Parse(logout.PostLogoutRedirectURI)
+	query := u.Query()
+	query.Set("state", logout.State)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackChannelLogoutClient is a client that registered for back-channel
+// logout.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRegistration
+type BackChannelLogoutClient interface {
+	Client
+	BackChannelLogoutURI() string
+	BackChannelLogoutSessionRequired() bool
+}
+
+// SessionClientLister is implemented by a LogoutService that knows the
+// clients a resource owner used during a session.
+type SessionClientLister interface {
+	SessionClients(ctx context.Context, session *Session) ([]Client, error)
+}
+
+// LogoutDelivery is the result of delivering a logout token to a client.
+type LogoutDelivery struct {
+	ClientID   string
+	URI        string
+	Attempts   int
+	StatusCode int
+	Err        error
+}
+
+// backChannelLogoutEvent is the logout token event identifier.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenLifetime is the lifetime of logout tokens.
+const logoutTokenLifetime = 2 * time.Minute
+
+// BackChannelLogout notifies clients of ended sessions by sending
+// logout tokens directly to them.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html
+type BackChannelLogout struct {
+	// MaxAttempts is the number of delivery attempts per client.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles with
+	// every further retry.
+	Backoff time.Duration
+	// Timeout bounds the deliveries started by the end session
+	// endpoint, including all retries.
+	Timeout time.Duration
+
+	issuer     string
+	signer     JWTSigner
+	httpClient *http.Client
+	logger     Log
+}
+
+// NewBackChannelLogout creates a new back-channel logout dispatcher.
+// It signs logout tokens with signer and sends them with httpClient. It
+// fails if signer is nil.
+func NewBackChannelLogout(issuer string, signer JWTSigner, httpClient *http.Client, logger Log) (*BackChannelLogout, error) {
+	if signer == nil {
+		return nil, errors.New("oauth2: back-channel logout requires a signer")
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &BackChannelLogout{
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		Timeout:     time.Minute,
+		issuer:      issuer,
+		signer:      signer,
+		httpClient:  httpClient,
+		logger:      logger,
+	}, nil
+}
+
+// Dispatch sends logout tokens for the session to all clients registered
+// for back-channel logout and returns the delivery result for each of
+// them.
+func (b *BackChannelLogout) Dispatch(ctx context.Context, session *Session, clients []Client) []LogoutDelivery {
+	var targets []BackChannelLogoutClient
+	for _, client := range clients {
+		if bc, ok := client.(BackChannelLogoutClient); ok && bc.BackChannelLogoutURI() != "" {
+			targets = append(targets, bc)
+		}
+	}
+
+	deliveries := make([]LogoutDelivery, len(targets))
+
+	var wg sync.WaitGroup
+	for i, client := range targets {
+		wg.Add(1)
+		go func(i int, client BackChannelLogoutClient) {
+			defer wg.Done()
+			deliveries[i] = b.deliver(ctx, session, client)
+			if deliveries[i].Err != nil && b.logger != nil {
+				b.logger.Println(deliveries[i].Err)
+			}
+		}(i, client)
+	}
+	wg.Wait()
+
+	return deliveries
+}
+
+// dispatchBackChannelLogout dispatches the logout of the session to the
+// clients and emits an event for every failed delivery. It is run in
+// its own goroutine, so ctx must outlive the request.
+func (h *Handler) dispatchBackChannelLogout(ctx context.Context, session *Session, clients []Client) {
+	if h.BackChannelLogout.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.BackChannelLogout.Timeout)
+		defer cancel()
+	}
+
+	for _, delivery := range h.BackChannelLogout.Dispatch(ctx, session, clients) {
+		if delivery.Err != nil {
+			emitEvent(ctx, &Event{
+				Type:     EventBackChannelLogoutFailed,
+				ClientID: delivery.ClientID,
+				Subject:  session.Subject,
+				Error:    errorCode(delivery.Err),
+			})
+		}
+	}
+}
+
+func (b *BackChannelLogout) deliver(ctx context.Context, session *Session, client BackChannelLogoutClient) LogoutDelivery {
+	delivery := LogoutDelivery{
+		ClientID: client.Identifier(),
+		URI:      client.BackChannelLogoutURI(),
+	}
+
+	if client.BackChannelLogoutSessionRequired() && session.ID == "" {
+		delivery.Err = fmt.Errorf("oauth2: back-channel logout of client %s requires a session id", delivery.ClientID)
+		return delivery
+	}
+
+	token, err := b.logoutToken(session, delivery.ClientID)
+	if err != nil {
+		delivery.Err = err
+		return delivery
+	}
+
+	body := url.Values{"logout_token": {token}}.Encode()
+	backoff := b.Backoff
+
+	for delivery.Attempts < b.MaxAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				delivery.Err = ctx.Err()
+				return delivery
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		delivery.Attempts++
+
+		var retry bool
+		delivery.StatusCode, retry, delivery.Err = b.post(ctx, delivery.URI, body)
+		if !retry {
+			break
+		}
+	}
+
+	return delivery
+}
+
+// post sends the logout token. Network errors and server errors are
+// retried, other responses are final.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCResponse
+func (b *BackChannelLogout) post(ctx context.Context, uri, body string) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, uri, strings.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return 0, ctx.Err() == nil, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, false, nil
+	}
+
+	err = fmt.Errorf("oauth2: back-channel logout to %s failed with status %d", uri, resp.StatusCode)
+	return resp.StatusCode, isServerError(resp.StatusCode), err
+}
+
+// logoutToken creates a signed logout token for the client.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+func (b *BackChannelLogout) logoutToken(session *Session, clientID string) (string, error) {
+	if b.signer == nil {
+		return "", errors.New("oauth2: back-channel logout requires a signer")
+	}
+	if session.Subject == "" && session.ID == "" {
+		return "", errors.New("oauth2: logout token requires a subject or session id")
+	}
+
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": b.issuer,
+		"aud": clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(logoutTokenLifetime).Unix(),
+		"jti": jti,
+		"events": map[string]interface{}{
+			backChannelLogoutEvent: map[string]interface{}{},
+		},
+	}
+	if session.Subject != "" {
+		claims["sub"] = session.Subject
+	}
+	if session.ID != "" {
+		claims["sid"] = session.ID
+	}
+
+	return signJWT(b.signer, claims)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testBackChannelClient struct {
+	testClient
+	uri             string
+	sessionRequired bool
+}
+
+func (c *testBackChannelClient) BackChannelLogoutURI() string {
+	return c.uri
+}
+
+func (c *testBackChannelClient) BackChannelLogoutSessionRequired() bool {
+	return c.sessionRequired
+}
+
+func TestBackChannelLogoutDispatch(t *testing.T) {
+	var failures int32
+	claims := make(chan map[string]interface{}, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(req.PostFormValue("logout_token"), ".")
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var c map[string]interface{}
+		json.Unmarshal(payload, &c)
+		claims <- c
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&failures, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	mux.HandleFunc("/reject", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	b, err := NewBackChannelLogout("https://as.example.com", NewHMACSigner("", []byte("secret")), srv.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Backoff = time.Millisecond
+
+	clients := []Client{
+		&testBackChannelClient{testClient: testClient{id: "ok"}, uri: srv.URL + "/ok"},
+		&testBackChannelClient{testClient: testClient{id: "flaky"}, uri: srv.URL + "/flaky"},
+		&testBackChannelClient{testClient: testClient{id: "reject"}, uri: srv.URL + "/reject"},
+		&testBackChannelClient{testClient: testClient{id: "sid"}, uri: srv.URL + "/ok", sessionRequired: true},
+		&testClient{id: "none"},
+	}
+
+	deliveries := b.Dispatch(context.Background(), &Session{Subject: "alice"}, clients)
+
+	expected := []struct {
+		clientID string
+		attempts int
+		status   int
+		failed   bool
+	}{
+		{"ok", 1, http.StatusOK, false},
+		{"flaky", 3, http.StatusOK, false},
+		{"reject", 1, http.StatusBadRequest, true},
+		{"sid", 0, 0, true},
+	}
+
+	if len(deliveries) != len(expected) {
+		t.Fatalf("Dispatch => %d deliveries, expected %d", len(deliveries), len(expected))
+	}
+	for i, e := range expected {
+		d := deliveries[i]
+		if d.ClientID != e.clientID || d.Attempts != e.attempts || d.StatusCode != e.status || (d.Err != nil) != e.failed {
+			t.Errorf("Dispatch delivery %d => %+v, expected %+v", i, d, e)
+		}
+	}
+
+	c := <-claims
+	if c["iss"] != "https://as.example.com" || c["aud"] != "ok" || c["sub"] != "alice" || c["jti"] == "" {
+		t.Errorf("logout token claims => %v", c)
+	}
+	if _, ok := c["events"].(map[string]interface{})[backChannelLogoutEvent]; !ok {
+		t.Errorf("logout token events => %v, expected %s", c["events"], backChannelLogoutEvent)
+	}
+}
+
+type testSessionLogoutService struct {
+	testLogoutService
+	clients []Client
+}
+
+func (s testSessionLogoutService) SessionClients(ctx context.Context, session *Session) ([]Client, error) {
+	return s.clients, nil
+}
+
+type testEventSink chan *Event
+
+func (s testEventSink) Event(ctx context.Context, event *Event) {
+	s <- event
+}
+
+func TestEndSessionBackChannelLogoutFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	events := make(testEventSink, 1)
+	h := NewHandler(testStorer{}, nil)
+	h.Authenticator = &testAuthenticator{&Session{Subject: "alice"}}
+	h.LogoutService = testSessionLogoutService{clients: []Client{
+		&testBackChannelClient{testClient: testClient{id: "foo"}, uri: srv.URL},
+	}}
+	h.BackChannelLogout = newTestBackChannelLogout(t, srv)
+	h.EventSink = events
+
+	h.EndSession(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/logout?confirm=1", nil))
+
+	select {
+	case event := <-events:
+		if event.Type != EventBackChannelLogoutFailed || event.ClientID != "foo" || event.Subject != "alice" || event.Outcome != OutcomeFailure {
+			t.Errorf("EndSession => %+v, expected failed back-channel logout of foo", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("EndSession => no event, expected failed back-channel logout")
+	}
+}
+
+func TestDispatchBackChannelLogoutTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	h := NewHandler(testStorer{}, nil)
+	h.BackChannelLogout = newTestBackChannelLogout(t, srv)
+	h.BackChannelLogout.Backoff = time.Hour
+	h.BackChannelLogout.Timeout = 10 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		h.dispatchBackChannelLogout(context.Background(), &Session{Subject: "alice"}, []Client{
+			&testBackChannelClient{testClient: testClient{id: "foo"}, uri: srv.URL},
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("dispatchBackChannelLogout => still retrying, expected timeout")
+	}
+}
+
+func newTestBackChannelLogout(t *testing.T, srv *httptest.Server) *BackChannelLogout {
+	b, err := NewBackChannelLogout("https://as.example.com", NewHMACSigner("", []byte("secret")), srv.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewBackChannelLogoutWithoutSigner(t *testing.T) {
+	if b, err := NewBackChannelLogout("https://as.example.com", nil, nil, nil); b != nil || err == nil {
+		t.Errorf("NewBackChannelLogout(nil signer) => %v, %v, expected error", b, err)
+	}
+
+	delivery := (&BackChannelLogout{MaxAttempts: 1}).deliver(context.Background(), &Session{Subject: "alice"}, &testBackChannelClient{testClient: testClient{id: "foo"}, uri: "https://client.example.com/logout"})
+	if delivery.Err == nil {
+		t.Errorf("deliver(zero BackChannelLogout) => %+v, expected error", delivery)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// FrontChannelLogoutClient is a client that registered for front-channel
+// logout.
+//
+// https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+type FrontChannelLogoutClient interface {
+	Client
+	FrontChannelLogoutURI() string
+	FrontChannelLogoutSessionRequired() bool
+}
+
+// FrontChannelLogoutPage is the data the front-channel logout template
+// is executed with.
+type FrontChannelLogoutPage struct {
+	// Frames holds the front-channel logout URIs to render in iframes.
+	Frames []string
+	// RedirectURI is the location to continue to once all frames
+	// loaded, if any.
+	RedirectURI string
+}
+
+// DefaultFrontChannelLogoutTemplate renders hidden iframes for all
+// clients and continues to the post logout redirection URI once they
+// loaded.
+var DefaultFrontChannelLogoutTemplate = template.Must(template.New("frontchannel_logout").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Logging out</title></head>
+<body{{if .RedirectURI}} onload="window.location.replace(document.getElementById('continue').href)"{{end}}>
+{{- range .Frames}}
+<iframe src="{{.}}" style="display:none"></iframe>
+{{- end}}
+{{- if .RedirectURI}}
+<p><a id="continue" href="{{.RedirectURI}}">Continue</a></p>
+{{- else}}
+<p>You have been logged out.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// frontChannelLogoutURIs returns the front-channel logout URIs of the
+// clients, with iss and sid added for clients that require them. sid
+// is omitted if the session has no identifier.
+//
+// https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+func frontChannelLogoutURIs(issuer string, session *Session, clients []Client) []string {
+	var uris []string
+	for _, client := range clients {
+		fc, ok := client.(FrontChannelLogoutClient)
+		if !ok || fc.FrontChannelLogoutURI() == "" {
+			continue
+		}
+
+		uri := fc.FrontChannelLogoutURI()
+		if fc.FrontChannelLogoutSessionRequired() {
+			u, err := url.Parse(uri)
+			if err != nil {
+				continue
+			}
+			query := u.Query()
+			query.Set("iss", issuer)
+			if session.ID != "" {
+				query.Set("sid", session.ID)
+			}
+			u.RawQuery = query.Encode()
+			uri = u.String()
+		}
+
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+func (h *Handler) writeFrontChannelLogout(w http.ResponseWriter, frames []string, redirectURI string) {
+	tmpl := h.FrontChannelLogoutTemplate
+	if tmpl == nil {
+		tmpl = DefaultFrontChannelLogoutTemplate
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Pragma", "no-cache")
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := tmpl.Execute(w, &FrontChannelLogoutPage{frames, redirectURI}); err != nil {
+		h.logger.Println(err)
+	}
+}
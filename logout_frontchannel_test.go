@@ -0,0 +1,93 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testFrontChannelClient struct {
+	testClient
+	uri             string
+	sessionRequired bool
+}
+
+func (c *testFrontChannelClient) FrontChannelLogoutURI() string {
+	return c.uri
+}
+
+func (c *testFrontChannelClient) FrontChannelLogoutSessionRequired() bool {
+	return c.sessionRequired
+}
+
+func TestFrontChannelLogoutURIs(t *testing.T) {
+	clients := []Client{
+		&testFrontChannelClient{testClient: testClient{id: "plain"}, uri: "https://plain.example.com/logout"},
+		&testFrontChannelClient{testClient: testClient{id: "sid"}, uri: "https://sid.example.com/logout?foo=bar", sessionRequired: true},
+		&testFrontChannelClient{testClient: testClient{id: "empty"}},
+		&testClient{id: "none"},
+	}
+
+	tests := []struct {
+		session  *Session
+		expected []string
+	}{
+		{&Session{Subject: "alice", ID: "s1"}, []string{
+			"https://plain.example.com/logout",
+			"https://sid.example.com/logout?foo=bar&iss=https%3A%2F%2Fas.example.com&sid=s1",
+		}},
+		{&Session{Subject: "alice"}, []string{
+			"https://plain.example.com/logout",
+			"https://sid.example.com/logout?foo=bar&iss=https%3A%2F%2Fas.example.com",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := frontChannelLogoutURIs("https://as.example.com", tt.session, clients)
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("frontChannelLogoutURIs(%+v) => %v, expected %v", tt.session, got, tt.expected)
+		}
+	}
+}
+
+func TestEndSessionFrontChannelLogout(t *testing.T) {
+	client := testPostLogoutClient{&testClient{id: "foo"}, []string{"https://client.example.com/logout"}}
+	h := NewHandler(testStorer{"foo": client}, nil)
+	h.Issuer = "https://as.example.com"
+	h.Authenticator = &testAuthenticator{&Session{Subject: "alice", ID: "s1"}}
+	h.LogoutService = testSessionLogoutService{clients: []Client{
+		&testFrontChannelClient{testClient: testClient{id: "foo"}, uri: "https://client.example.com/fc?a=1&b=2", sessionRequired: true},
+	}}
+	h.FrontChannelLogout = true
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"confirm=1", []string{
+			`<iframe src="https://client.example.com/fc?a=1&amp;b=2&amp;iss=https%3A%2F%2Fas.example.com&amp;sid=s1"`,
+			"You have been logged out.",
+		}},
+		{"confirm=1&client_id=foo&post_logout_redirect_uri=https://client.example.com/logout&state=x%22y", []string{
+			`<a id="continue" href="https://client.example.com/logout?state=x%22y">`,
+			`onload=`,
+		}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.EndSession(w, httptest.NewRequest(http.MethodGet, "/logout?"+tt.query, nil))
+
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("EndSession(%s) => %d %v, expected uncached page", tt.query, w.Code, w.Header())
+		}
+		for _, expected := range tt.expected {
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("EndSession(%s) => %s, expected %s", tt.query, w.Body.String(), expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPostLogoutClient struct {
+	*testClient
+	postLogoutRedirectURIs []string
+}
+
+func (c testPostLogoutClient) IsAllowedPostLogoutRedirectURI(uri string) bool {
+	return containsString(c.postLogoutRedirectURIs, uri)
+}
+
+type testLogoutService struct{}
+
+func (testLogoutService) VerifyIDTokenHint(ctx context.Context, hint string) (*IDTokenHint, error) {
+	if hint != "valid" {
+		return nil, errors.New("invalid id_token_hint")
+	}
+	return &IDTokenHint{Subject: "alice", ClientID: "foo"}, nil
+}
+
+func (testLogoutService) ConfirmLogout(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) (bool, error) {
+	if req.FormValue("confirm") == "" {
+		w.Write([]byte("confirm logout"))
+		return false, nil
+	}
+	return true, nil
+}
+
+func (testLogoutService) EndSession(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) error {
+	w.Header().Set("X-Session-Ended", "true")
+	return nil
+}
+
+func (testLogoutService) LoggedOut(w http.ResponseWriter, req *http.Request, logout *LogoutRequest) {
+	w.Write([]byte("logged out"))
+}
+
+func TestEndSession(t *testing.T) {
+	storer := testStorer{
+		"foo": testPostLogoutClient{&testClient{id: "foo"}, []string{"https://client.example.com/logout"}},
+		"bar": &testClient{id: "bar"},
+	}
+	h := NewHandler(storer, nil)
+	h.LogoutService = testLogoutService{}
+
+	tests := []struct {
+		query    string
+		status   int
+		ended    bool
+		location string
+		body     string
+	}{
+		{"", http.StatusOK, false, "", "confirm logout"},
+		{"confirm=1", http.StatusOK, true, "", "logged out"},
+		{"confirm=1&client_id=foo&post_logout_redirect_uri=https://client.example.com/logout", http.StatusFound, true, "https://client.example.com/logout", ""},
+		{"confirm=1&client_id=foo&post_logout_redirect_uri=https://client.example.com/logout&state=xyz", http.StatusFound, true, "https://client.example.com/logout?state=xyz", ""},
+		{"confirm=1&id_token_hint=valid&post_logout_redirect_uri=https://client.example.com/logout&state=xyz", http.StatusFound, true, "https://client.example.com/logout?state=xyz", ""},
+		{"confirm=1&client_id=foo&post_logout_redirect_uri=https://attacker.example.com/logout", http.StatusBadRequest, false, "", "invalid_request"},
+		{"confirm=1&client_id=bar&post_logout_redirect_uri=https://client.example.com/logout", http.StatusBadRequest, false, "", "invalid_request"},
+		{"confirm=1&post_logout_redirect_uri=https://client.example.com/logout", http.StatusBadRequest, false, "", "invalid_request"},
+		{"confirm=1&client_id=baz", http.StatusBadRequest, false, "", "invalid_client"},
+		{"confirm=1&id_token_hint=invalid", http.StatusBadRequest, false, "", "invalid_request"},
+		{"confirm=1&id_token_hint=valid&client_id=bar", http.StatusBadRequest, false, "", "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			h.EndSession(w, httptest.NewRequest(http.MethodGet, "/logout?"+tt.query, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("EndSession(%s) => %d, expected %d", tt.query, w.Code, tt.status)
+			}
+			if ended := w.Header().Get("X-Session-Ended") != ""; ended != tt.ended {
+				t.Errorf("EndSession(%s) ended => %t, expected %t", tt.query, ended, tt.ended)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("EndSession(%s) => %s, expected %s", tt.query, location, tt.location)
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("EndSession(%s) => %s, expected %s", tt.query, w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestEndSessionWithoutService(t *testing.T) {
+	h := NewHandler(testStorer{}, nil)
+
+	w := httptest.NewRecorder()
+	h.EndSession(w, httptest.NewRequest(http.MethodGet, "/logout", nil))
+
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "invalid_request") {
+		t.Errorf("EndSession => %d %s, expected plain %d", w.Code, w.Body.String(), http.StatusNotFound)
+	}
+}
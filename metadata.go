@@ -0,0 +1,69 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Metadata publishes the authorization server metadata. It is typically
+// served at /.well-known/oauth-authorization-server. Endpoint locations
+// and other values unknown to the handler are taken from ExtraMetadata.
+//
+// https://tools.ietf.org/html/rfc8414#section-3
+func (h *Handler) Metadata(w http.ResponseWriter, req *http.Request) {
+	m := make(map[string]interface{}, len(h.ExtraMetadata)+8)
+	for k, v := range h.ExtraMetadata {
+		m[k] = v
+	}
+
+	if h.Issuer != "" {
+		m["issuer"] = h.Issuer
+		m["authorization_response_iss_parameter_supported"] = true
+	}
+
+	grantTypes := make([]string, 0, len(h.tokenGTs)+len(h.authorizeGTs))
+	for name := range h.tokenGTs {
+		grantTypes = append(grantTypes, name)
+	}
+	responseTypes := make([]string, 0, len(h.authorizeGTs))
+	for name, gt := range h.authorizeGTs {
+		responseTypes = append(responseTypes, name)
+		if _, ok := gt.(TokenGrantType); !ok {
+			grantTypes = append(grantTypes, gt.Identifier())
+		}
+	}
+	sort.Strings(grantTypes)
+	sort.Strings(responseTypes)
+
+	for _, gt := range h.tokenGTs {
+		if _, ok := gt.(BackchannelAuthenticationGrantType); ok {
+			m["backchannel_token_delivery_modes_supported"] = []string{CIBAPollMode, CIBAPingMode, CIBAPushMode}
+		}
+		if uc, ok := gt.(interface{ BackchannelUserCodeParameterSupported() bool }); ok && uc.BackchannelUserCodeParameterSupported() {
+			m["backchannel_user_code_parameter_supported"] = true
+		}
+	}
+
+	m["grant_types_supported"] = grantTypes
+	m["response_types_supported"] = responseTypes
+
+	responseModes := []string{ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost}
+	if h.ResponseSigner != nil && h.Issuer != "" {
+		responseModes = append(responseModes, ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT)
+		m["authorization_signing_alg_values_supported"] = []string{h.ResponseSigner.Algorithm()}
+	}
+	m["response_modes_supported"] = responseModes
+
+	if len(h.AuthorizationDetailsTypes) > 0 {
+		types := make([]string, 0, len(h.AuthorizationDetailsTypes))
+		for typ := range h.AuthorizationDetailsTypes {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		m["authorization_details_types_supported"] = types
+	}
+
+	writeJSON(w, h.logger, http.StatusOK, m, nil)
+}
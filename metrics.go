@@ -0,0 +1,191 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the request
+// latency histogram buckets.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects metrics of the token and authorize endpoints and the
+// grant types. It is an http.Handler serving them in the Prometheus text
+// exposition format.
+//
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[requestMetric]uint64
+	latencies map[latencyMetric]*histogram
+	issued    map[issuedMetric]uint64
+}
+
+type requestMetric struct {
+	endpoint, grantType, clientID, err string
+}
+
+type latencyMetric struct {
+	endpoint, grantType string
+}
+
+type issuedMetric struct {
+	grantType, clientID string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics creates new metrics with latency histograms using the given
+// bucket upper bounds in seconds, or DefaultLatencyBuckets if none are
+// given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:   buckets,
+		requests:  make(map[requestMetric]uint64),
+		latencies: make(map[latencyMetric]*histogram),
+		issued:    make(map[issuedMetric]uint64),
+	}
+}
+
+// event counts the tokens issued by the grant types.
+func (m *Metrics) event(event *Event) {
+	if event.Type != EventTokenIssued && event.Type != EventAuthorizationIssued {
+		return
+	}
+
+	m.mu.Lock()
+	m.issued[issuedMetric{event.GrantType, event.ClientID}]++
+	m.mu.Unlock()
+}
+
+// observe records a request to the endpoint started at start. The grant
+// type, client and error are taken from the events of the request. Only
+// authenticated clients are recorded, so unknown client identifiers do
+// not create new series.
+func (m *Metrics) observe(endpoint string, recorder *eventRecorder, start time.Time) {
+	if recorder == nil {
+		return
+	}
+
+	seconds := time.Since(start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestMetric{endpoint, recorder.base.GrantType, recorder.client, recorder.err}]++
+
+	key := latencyMetric{endpoint, recorder.base.GrantType}
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[key] = h
+	}
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	bw.Flush()
+}
+
+func (m *Metrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lines []string
+
+	fmt.Fprintln(w, "# HELP oauth2_requests_total Requests by endpoint, grant type, client and error.")
+	fmt.Fprintln(w, "# TYPE oauth2_requests_total counter")
+	for k, v := range m.requests {
+		lines = append(lines, "oauth2_requests_total"+labels("endpoint", k.endpoint, "grant_type", k.grantType, "client_id", k.clientID, "error", k.err)+" "+strconv.FormatUint(v, 10))
+	}
+	writeSorted(w, lines)
+
+	lines = lines[:0]
+	fmt.Fprintln(w, "# HELP oauth2_tokens_issued_total Tokens issued by grant type and client.")
+	fmt.Fprintln(w, "# TYPE oauth2_tokens_issued_total counter")
+	for k, v := range m.issued {
+		lines = append(lines, "oauth2_tokens_issued_total"+labels("grant_type", k.grantType, "client_id", k.clientID)+" "+strconv.FormatUint(v, 10))
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP oauth2_request_duration_seconds Request latency by endpoint and grant type.")
+	fmt.Fprintln(w, "# TYPE oauth2_request_duration_seconds histogram")
+	keys := make([]latencyMetric, 0, len(m.latencies))
+	for k := range m.latencies {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].grantType < keys[j].grantType
+	})
+	for _, k := range keys {
+		h := m.latencies[k]
+		for i, upper := range m.buckets {
+			le := strconv.FormatFloat(upper, 'g', -1, 64)
+			fmt.Fprintf(w, "oauth2_request_duration_seconds_bucket%s %d\n", labels("endpoint", k.endpoint, "grant_type", k.grantType, "le", le), h.counts[i])
+		}
+		fmt.Fprintf(w, "oauth2_request_duration_seconds_bucket%s %d\n", labels("endpoint", k.endpoint, "grant_type", k.grantType, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "oauth2_request_duration_seconds_sum%s %s\n", labels("endpoint", k.endpoint, "grant_type", k.grantType), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "oauth2_request_duration_seconds_count%s %d\n", labels("endpoint", k.endpoint, "grant_type", k.grantType), h.count)
+	}
+}
+
+func writeSorted(w *bufio.Writer, lines []string) {
+	sort.Strings(lines)
+	for _, line := range lines {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the label pairs.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		labelEscaper.WriteString(&b, pairs[i+1])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	storer := testStorer{"foo": &testClient{id: "foo", secret: "bar", grantTypes: []string{PasswordGrantType}}}
+
+	h := NewHandler(storer, nil, NewPasswordGrantType(nil, testPasswordService{}))
+	h.Metrics = NewMetrics(1)
+
+	for _, password := range []string{"secret", "secret", "wrong"} {
+		form := url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("foo", "bar")
+		h.Token(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	h.Metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	for _, expected := range []string{
+		"# TYPE oauth2_requests_total counter\n",
+		`oauth2_requests_total{endpoint="token",grant_type="password",client_id="foo",error=""} 2` + "\n",
+		`oauth2_requests_total{endpoint="token",grant_type="password",client_id="foo",error="invalid_grant"} 1` + "\n",
+		`oauth2_tokens_issued_total{grant_type="password",client_id="foo"} 2` + "\n",
+		`oauth2_request_duration_seconds_bucket{endpoint="token",grant_type="password",le="1"} 3` + "\n",
+		`oauth2_request_duration_seconds_bucket{endpoint="token",grant_type="password",le="+Inf"} 3` + "\n",
+		`oauth2_request_duration_seconds_count{endpoint="token",grant_type="password"} 3` + "\n",
+	} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("ServeHTTP => %s, expected %s", w.Body.String(), expected)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	got := labels("a", `x"y\z`, "b", "1\n2")
+	expected := `{a="x\"y\\z",b="1\n2"}`
+	if got != expected {
+		t.Errorf("labels => %s, expected %s", got, expected)
+	}
+}
@@ -22,6 +22,10 @@ type Log interface {
 // whether the application executes on a server, a desktop, or other
 // devices).
 //
+// Authorization requests must include the redirect_uri parameter,
+// unless the client implements RedirectURIsClient and registered exactly
+// one redirection URI.
+//
 // https://tools.ietf.org/html/rfc6749#section-1.1
 type Client interface {
 	Identifier() string
@@ -31,6 +35,16 @@ type Client interface {
 	Authenticate(secret string) bool
 }
 
+// StatePolicyClient is a client deciding whether authorization
+// requests must include the state parameter. The state parameter is
+// RECOMMENDED, so clients not implementing it must include it.
+//
+// https://tools.ietf.org/html/rfc6749#section-4.1.1
+type StatePolicyClient interface {
+	Client
+	RequiresState() bool
+}
+
 // Storer finds clients by their identifier.
 type Storer interface {
 	FindClient(ctx context.Context, id string) (Client, error)
@@ -54,3 +68,13 @@ type AuthorizeGrantType interface {
 	ResponseName() string
 	Respond(w http.ResponseWriter, req *http.Request, reqParams url.Values, client Client, redirectURI, state string)
 }
+
+// ResponseModeGrantType is an authorize grant type with a default
+// response mode other than query. Grant types not implementing it
+// default to the query encoding.
+//
+// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+type ResponseModeGrantType interface {
+	AuthorizeGrantType
+	DefaultResponseMode() string
+}
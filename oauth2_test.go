@@ -0,0 +1,30 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+type testClient struct {
+	id           string
+	secret       string
+	redirectURIs []string
+	grantTypes   []string
+}
+
+func (c *testClient) Identifier() string {
+	return c.id
+}
+
+func (c *testClient) IsAllowedRedirectURI(uri string) bool {
+	return containsString(c.redirectURIs, uri)
+}
+
+func (c *testClient) IsAllowedGrantType(identifier string) bool {
+	return containsString(c.grantTypes, identifier)
+}
+
+func (c *testClient) IsConfidential() bool {
+	return c.secret != ""
+}
+
+func (c *testClient) Authenticate(secret string) bool {
+	return c.secret == secret
+}
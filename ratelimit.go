@@ -0,0 +1,385 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore holds the counters of a RateLimiter. Implementations
+// backed by a shared database allow limiting across several servers.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of the key. The bucket holds up
+	// to burst tokens and is refilled with rate tokens per second. If it
+	// is empty, Take returns how long to wait for the next token.
+	Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (time.Duration, error)
+	// Fail records a failed attempt of the key.
+	Fail(ctx context.Context, key string, now time.Time) error
+	// Failures returns the number of consecutive failed attempts of the
+	// key and the time of the last one.
+	Failures(ctx context.Context, key string) (int, time.Time, error)
+	// Reset clears the failed attempts of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// RateLimit configures a token bucket. A zero Burst disables it.
+type RateLimit struct {
+	// Rate is the number of requests per second.
+	Rate float64
+	// Burst is the number of requests allowed at once.
+	Burst int
+}
+
+// RateLimiter protects the token and authorize endpoints against brute
+// force attacks. Requests are limited per client, per username of
+// password grants and IP, and per IP. Once a client or username failed
+// to authenticate LockoutThreshold times in a row from an IP, it is
+// locked out for that IP for LockoutBase, doubling with every further
+// failure up to LockoutMax.
+//
+// Username limits and lockouts are per IP, so that an attacker cannot
+// lock a known username out everywhere. In turn, an attacker spreading
+// guesses for a username over many IPs is not blocked. Such attempts
+// are counted per username across all IPs by the UsernameAlert bucket;
+// once it is empty, EventUsernameAttemptsExceeded is emitted and
+// logged, but requests are not refused.
+type RateLimiter struct {
+	Client   RateLimit
+	Username RateLimit
+	IP       RateLimit
+
+	// UsernameAlert limits the password grant attempts for a username
+	// across all IPs before alerting.
+	UsernameAlert RateLimit
+
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+
+	// Store holds the counters. If it is a MemoryRateLimitStore, as
+	// created by NewRateLimiter(nil), run its Sweep to remove idle
+	// counters.
+	Store RateLimitStore
+}
+
+// NewRateLimiter creates a new rate limiter with defaults suitable for
+// most servers. If store is nil, counters are held in a
+// MemoryRateLimitStore.
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+
+	return &RateLimiter{
+		Client:           RateLimit{Rate: 10, Burst: 50},
+		Username:         RateLimit{Rate: 1, Burst: 10},
+		IP:               RateLimit{Rate: 20, Burst: 100},
+		UsernameAlert:    RateLimit{Rate: 0.1, Burst: 100},
+		LockoutThreshold: 5,
+		LockoutBase:      time.Second,
+		LockoutMax:       15 * time.Minute,
+		Store:            store,
+	}
+}
+
+// rateLimitKeys identifies the buckets and lockouts of a request.
+type rateLimitKeys struct {
+	client, username, ip string
+
+	// usernameAlert counts the attempts of the username across all IPs.
+	usernameAlert string
+
+	// clientLockout and usernameLockout pair the client and username
+	// with the IP.
+	clientLockout, usernameLockout string
+}
+
+func (l *RateLimiter) keys(ip, clientID, username string) rateLimitKeys {
+	var keys rateLimitKeys
+	if clientID != "" {
+		keys.client = "client:" + clientID
+		keys.clientLockout = "lockout:" + ip + ":client:" + clientID
+	}
+	if username != "" {
+		keys.username = "username:" + ip + ":" + username
+		keys.usernameAlert = "username:" + username
+		keys.usernameLockout = "lockout:" + ip + ":username:" + username
+	}
+	if ip != "" {
+		keys.ip = "ip:" + ip
+	}
+	return keys
+}
+
+// allow returns how long to wait before the request is allowed, or zero
+// if it is allowed now.
+func (l *RateLimiter) allow(ctx context.Context, keys rateLimitKeys) (time.Duration, error) {
+	now := time.Now()
+
+	for _, key := range []string{keys.clientLockout, keys.usernameLockout} {
+		if key == "" {
+			continue
+		}
+		if wait, err := l.lockedOut(ctx, key, now); wait > 0 || err != nil {
+			return wait, err
+		}
+	}
+
+	buckets := []struct {
+		key   string
+		limit RateLimit
+	}{
+		{keys.ip, l.IP},
+		{keys.client, l.Client},
+		{keys.username, l.Username},
+	}
+	for _, b := range buckets {
+		if b.key == "" || b.limit.Burst <= 0 {
+			continue
+		}
+		if wait, err := l.Store.Take(ctx, b.key, b.limit.Rate, b.limit.Burst, now); wait > 0 || err != nil {
+			return wait, err
+		}
+	}
+
+	return 0, nil
+}
+
+// alert reports whether the attempts for the username across all IPs
+// exceed the UsernameAlert bucket.
+func (l *RateLimiter) alert(ctx context.Context, keys rateLimitKeys) (bool, error) {
+	if keys.usernameAlert == "" || l.UsernameAlert.Burst <= 0 {
+		return false, nil
+	}
+	wait, err := l.Store.Take(ctx, keys.usernameAlert, l.UsernameAlert.Rate, l.UsernameAlert.Burst, time.Now())
+	return wait > 0, err
+}
+
+func (l *RateLimiter) lockedOut(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	failures, last, err := l.Store.Failures(ctx, key)
+	if err != nil || l.LockoutThreshold <= 0 || failures < l.LockoutThreshold {
+		return 0, err
+	}
+
+	lockout := l.LockoutMax
+	if shift := failures - l.LockoutThreshold; shift < 62 {
+		if d := l.LockoutBase << uint(shift); d > 0 && d < lockout {
+			lockout = d
+		}
+	}
+
+	if wait := last.Add(lockout).Sub(now); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// fail records a failed authentication of the key.
+func (l *RateLimiter) fail(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return l.Store.Fail(ctx, key, time.Now())
+}
+
+// succeed clears the failed authentications of the key.
+func (l *RateLimiter) succeed(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return l.Store.Reset(ctx, key)
+}
+
+// writeRateLimited writes a 429 response asking the client to retry
+// after the given duration.
+func writeRateLimited(w http.ResponseWriter, logger Log, wait time.Duration, state string) {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	writeError(w, logger, http.StatusTooManyRequests, ErrTemporarilyUnavailable, state)
+}
+
+// NewMemoryRateLimitStore creates a new thread-safe rate limit store
+// holding counters in memory. Idle counters are removed by Sweep.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		counters: make(map[string]*rateLimitCounter),
+	}
+}
+
+var _ RateLimitStore = (*MemoryRateLimitStore)(nil)
+
+// MemoryRateLimitStore is an in-memory RateLimitStore.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]*rateLimitCounter
+}
+
+type rateLimitCounter struct {
+	tokens      float64
+	updated     time.Time
+	failures    int
+	lastFailure time.Time
+}
+
+func (s *MemoryRateLimitStore) counter(key string, burst int, now time.Time) *rateLimitCounter {
+	c, ok := s.counters[key]
+	if !ok {
+		c = &rateLimitCounter{tokens: float64(burst), updated: now}
+		s.counters[key] = c
+	}
+	return c
+}
+
+// Take takes a token from the bucket of the key.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counter(key, burst, now)
+	if elapsed := now.Sub(c.updated).Seconds(); elapsed > 0 {
+		c.tokens = math.Min(float64(burst), c.tokens+elapsed*rate)
+	}
+	c.updated = now
+
+	if c.tokens >= 1 {
+		c.tokens--
+		return 0, nil
+	}
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64), nil
+	}
+	return time.Duration((1 - c.tokens) / rate * float64(time.Second)), nil
+}
+
+// Fail records a failed attempt of the key.
+func (s *MemoryRateLimitStore) Fail(ctx context.Context, key string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counter(key, 0, time.Time{})
+	c.failures++
+	c.lastFailure = now
+	return nil
+}
+
+// Failures returns the number of consecutive failed attempts of the key
+// and the time of the last one.
+func (s *MemoryRateLimitStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok {
+		return 0, time.Time{}, nil
+	}
+	return c.failures, c.lastFailure, nil
+}
+
+// Reset clears the failed attempts of the key.
+func (s *MemoryRateLimitStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.counters[key]; ok {
+		c.failures = 0
+		c.lastFailure = time.Time{}
+	}
+	return nil
+}
+
+// Sweep removes counters that have not been used for idle every interval
+// until the context is done. It is typically run in its own goroutine.
+// idle must exceed the time to refill a bucket and the longest lockout.
+func (s *MemoryRateLimitStore) Sweep(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.deleteIdle(now.Add(-idle))
+		}
+	}
+}
+
+func (s *MemoryRateLimitStore) deleteIdle(before time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, c := range s.counters {
+		if c.updated.Before(before) && c.lastFailure.Before(before) {
+			delete(s.counters, key)
+		}
+	}
+}
+
+func (h *Handler) rateLimitKeys(req *http.Request, username string) rateLimitKeys {
+	if h.RateLimiter == nil {
+		return rateLimitKeys{}
+	}
+	clientID, _ := clientCredentials(req)
+	return h.RateLimiter.keys(h.remoteIP(req), clientID, username)
+}
+
+// limitRequest applies the RateLimiter, if any, to the request. It
+// writes an error response and returns false if the request is limited.
+func (h *Handler) limitRequest(w http.ResponseWriter, req *http.Request, keys rateLimitKeys, state string) bool {
+	if h.RateLimiter == nil {
+		return true
+	}
+
+	wait, err := h.RateLimiter.allow(req.Context(), keys)
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Println(err)
+		}
+		writeError(w, h.logger, http.StatusInternalServerError, ErrServerError, state)
+		return false
+	}
+	if wait > 0 {
+		emitEvent(req.Context(), &Event{Type: EventRateLimited, Error: ErrTemporarilyUnavailable.Error()})
+		writeRateLimited(w, h.logger, wait, state)
+		return false
+	}
+
+	alert, err := h.RateLimiter.alert(req.Context(), keys)
+	if err != nil && h.logger != nil {
+		h.logger.Println(err)
+	}
+	if alert {
+		if h.logger != nil {
+			h.logger.Println("oauth2: too many attempts for a username across all IPs")
+		}
+		emitEvent(req.Context(), &Event{Type: EventUsernameAttemptsExceeded})
+	}
+
+	return true
+}
+
+// recordAuthentication records a successful or failed authentication of
+// the key with the RateLimiter, if any.
+func (h *Handler) recordAuthentication(ctx context.Context, key string, ok bool) {
+	if h.RateLimiter == nil {
+		return
+	}
+
+	var err error
+	if ok {
+		err = h.RateLimiter.succeed(ctx, key)
+	} else {
+		err = h.RateLimiter.fail(ctx, key)
+	}
+	if err != nil && h.logger != nil {
+		h.logger.Println(err)
+	}
+}
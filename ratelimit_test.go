@@ -0,0 +1,135 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBuckets(t *testing.T) {
+	limiter := NewRateLimiter(nil)
+	limiter.IP = RateLimit{}
+	limiter.Client = RateLimit{Rate: 1, Burst: 2}
+
+	ctx := context.Background()
+	keys := limiter.keys("192.0.2.1", "foo", "")
+
+	for i := 0; i < 2; i++ {
+		if wait, err := limiter.allow(ctx, keys); wait != 0 || err != nil {
+			t.Fatalf("allow #%d => %v, %v, expected allowed", i, wait, err)
+		}
+	}
+	if wait, err := limiter.allow(ctx, keys); wait <= 0 || wait > time.Second || err != nil {
+		t.Errorf("allow after burst => %v, %v, expected wait up to 1s", wait, err)
+	}
+
+	other := limiter.keys("192.0.2.1", "bar", "")
+	if wait, err := limiter.allow(ctx, other); wait != 0 || err != nil {
+		t.Errorf("allow(bar) => %v, %v, expected allowed", wait, err)
+	}
+}
+
+func TestRateLimiterLockout(t *testing.T) {
+	limiter := NewRateLimiter(nil)
+	limiter.LockoutThreshold = 2
+	limiter.LockoutBase = time.Minute
+
+	ctx := context.Background()
+	keys := limiter.keys("192.0.2.1", "foo", "alice")
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 0},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{12, limiter.LockoutMax},
+	}
+
+	failures := 0
+	for _, tt := range tests {
+		for ; failures < tt.failures; failures++ {
+			if err := limiter.fail(ctx, keys.usernameLockout); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		wait, err := limiter.allow(ctx, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait > tt.expected || wait < tt.expected-time.Second {
+			t.Errorf("allow after %d failures => %v, expected %v", tt.failures, wait, tt.expected)
+		}
+	}
+
+	if err := limiter.succeed(ctx, keys.usernameLockout); err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := limiter.allow(ctx, keys); wait != 0 || err != nil {
+		t.Errorf("allow after success => %v, %v, expected allowed", wait, err)
+	}
+}
+
+func TestRateLimiterLockoutPerIP(t *testing.T) {
+	limiter := NewRateLimiter(nil)
+	limiter.LockoutThreshold = 1
+	limiter.LockoutBase = time.Minute
+
+	ctx := context.Background()
+	attacker := limiter.keys("192.0.2.1", "foo", "alice")
+	victim := limiter.keys("192.0.2.2", "foo", "alice")
+
+	for _, key := range []string{attacker.clientLockout, attacker.usernameLockout} {
+		if err := limiter.fail(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if wait, err := limiter.allow(ctx, attacker); wait <= 0 || err != nil {
+		t.Errorf("allow(192.0.2.1) => %v, %v, expected locked out", wait, err)
+	}
+	if wait, err := limiter.allow(ctx, victim); wait != 0 || err != nil {
+		t.Errorf("allow(192.0.2.2) => %v, %v, expected allowed", wait, err)
+	}
+}
+
+func TestRateLimiterUsernamePerIP(t *testing.T) {
+	limiter := NewRateLimiter(nil)
+	limiter.IP = RateLimit{}
+	limiter.Client = RateLimit{}
+	limiter.Username = RateLimit{Rate: 0.001, Burst: 1}
+	limiter.UsernameAlert = RateLimit{Rate: 0.001, Burst: 2}
+
+	ctx := context.Background()
+	tests := []struct {
+		ip      string
+		allowed bool
+		alert   bool
+	}{
+		{"192.0.2.1", true, false},
+		{"192.0.2.1", false, false},
+		{"192.0.2.2", true, false},
+		{"192.0.2.3", true, true},
+	}
+
+	for i, tt := range tests {
+		keys := limiter.keys(tt.ip, "foo", "alice")
+		wait, err := limiter.allow(ctx, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed := wait == 0; allowed != tt.allowed {
+			t.Errorf("allow #%d(%s) => %v, expected allowed %t", i, tt.ip, wait, tt.allowed)
+		}
+		if !tt.allowed {
+			continue
+		}
+		if alert, err := limiter.alert(ctx, keys); alert != tt.alert || err != nil {
+			t.Errorf("alert #%d(%s) => %t, %v, expected %t", i, tt.ip, alert, err, tt.alert)
+		}
+	}
+}
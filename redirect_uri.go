@@ -0,0 +1,178 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// RedirectURIsClient is a client exposing its registered redirection
+// URIs. The handler matches requested redirection URIs against them with
+// its RedirectURIMatcher instead of calling IsAllowedRedirectURI. If the
+// client registered exactly one redirection URI, authorization requests
+// may omit the redirect_uri parameter.
+//
+// https://tools.ietf.org/html/rfc6749#section-3.1.2.3
+type RedirectURIsClient interface {
+	Client
+	RegisteredRedirectURIs() []string
+}
+
+// RedirectURIMatcher matches requested redirection URIs against the
+// registered ones by exact string comparison. For native apps, the port
+// of loopback redirection URIs using http://127.0.0.1 or http://[::1]
+// is ignored. Private-use URI schemes are allowed if they contain a
+// period, such as com.example.app:/cb. Redirection URIs with a fragment
+// or an unsafe scheme never match.
+//
+// https://tools.ietf.org/html/rfc6749#section-3.1.2
+// https://tools.ietf.org/html/rfc8252#section-7
+type RedirectURIMatcher struct {
+	// Strict turns off all leniency as required by OAuth 2.1: ports of
+	// loopback redirection URIs must match, and http is only allowed for
+	// loopback redirection URIs.
+	//
+	// https://datatracker.ietf.org/doc/html/draft-ietf-oauth-v2-1
+	Strict bool
+}
+
+// DefaultRedirectURIMatcher is the lenient RedirectURIMatcher.
+var DefaultRedirectURIMatcher = &RedirectURIMatcher{}
+
+// unsafeSchemes can execute code or access local resources.
+var unsafeSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"file":       true,
+	"blob":       true,
+	"about":      true,
+}
+
+// Match reports whether the redirection URI matches one of the
+// registered redirection URIs.
+func (m *RedirectURIMatcher) Match(registered []string, uri string) bool {
+	if m.Validate(uri) != nil {
+		return false
+	}
+
+	for _, r := range registered {
+		if m.Validate(r) != nil {
+			continue
+		}
+		if r == uri {
+			return true
+		}
+		if !m.Strict && matchLoopback(r, uri) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error if the redirection URI must not be
+// registered or used.
+func (m *RedirectURIMatcher) Validate(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() {
+		return errors.New("oauth2: redirection URI must be absolute")
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return errors.New("oauth2: redirection URI must not include a fragment")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if unsafeSchemes[scheme] {
+		return errors.New("oauth2: redirection URI must not use the " + scheme + " scheme")
+	}
+	if (scheme == "http" || scheme == "https") && u.Host == "" {
+		return errors.New("oauth2: redirection URI must include a host")
+	}
+	// Private-use URI schemes are based on a domain name in reverse
+	// order, so they contain a period.
+	//
+	// https://tools.ietf.org/html/rfc8252#section-7.1
+	if scheme != "http" && scheme != "https" && !strings.Contains(scheme, ".") {
+		return errors.New("oauth2: private-use URI scheme must contain a period")
+	}
+	if m.Strict && scheme == "http" && !isLoopback(u.Hostname()) {
+		return errors.New("oauth2: redirection URI must use https unless it is a loopback redirection URI")
+	}
+
+	return nil
+}
+
+// matchLoopback reports whether the loopback redirection URIs are equal
+// except for their ports.
+//
+// https://tools.ietf.org/html/rfc8252#section-7.3
+func matchLoopback(registered, uri string) bool {
+	r, err := url.Parse(registered)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	if r.Scheme != "http" || u.Scheme != "http" || !isLoopback(r.Hostname()) || r.Hostname() != u.Hostname() {
+		return false
+	}
+	if r.User != nil || u.User != nil {
+		return false
+	}
+
+	return r.EscapedPath() == u.EscapedPath() && r.RawQuery == u.RawQuery
+}
+
+// isLoopback reports whether the host is a loopback IP literal. The
+// localhost name is not considered a loopback, as it may resolve to
+// another interface.
+//
+// https://tools.ietf.org/html/rfc8252#section-8.3
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.Equal(net.IPv4(127, 0, 0, 1)) || ip.Equal(net.IPv6loopback))
+}
+
+// isAllowedRedirectURI reports whether the client may use the
+// redirection URI.
+func (h *Handler) isAllowedRedirectURI(client Client, uri string) bool {
+	c, ok := client.(RedirectURIsClient)
+	if !ok {
+		return client.IsAllowedRedirectURI(uri)
+	}
+
+	m := h.RedirectURIMatcher
+	if m == nil {
+		m = DefaultRedirectURIMatcher
+	}
+	return m.Match(c.RegisteredRedirectURIs(), uri)
+}
+
+// resolveRedirectURI returns the verified redirection URI of an
+// authorization request. If the request omits it, the redirection URI
+// of a client that registered exactly one is used.
+func (h *Handler) resolveRedirectURI(client Client, uri string) (string, error) {
+	if uri == "" {
+		c, ok := client.(RedirectURIsClient)
+		if !ok || len(c.RegisteredRedirectURIs()) != 1 {
+			return "", NewError(ErrInvalidRequest, "The redirect_uri parameter is missing.")
+		}
+		uri = c.RegisteredRedirectURIs()[0]
+	}
+
+	if !h.isAllowedRedirectURI(client, uri) {
+		return "", NewError(ErrInvalidRequest, "The redirect_uri parameter does not match a registered redirection URI.")
+	}
+
+	return uri, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"testing"
+)
+
+func TestRedirectURIMatcherMatch(t *testing.T) {
+	tests := []struct {
+		strict     bool
+		registered string
+		uri        string
+		expected   bool
+	}{
+		{false, "https://client.example.com/cb", "https://client.example.com/cb", true},
+		{false, "https://client.example.com/cb", "https://client.example.com/cb/", false},
+		{false, "https://client.example.com/cb", "https://CLIENT.example.com/cb", false},
+		{false, "https://client.example.com/cb?a=b", "https://client.example.com/cb?a=b", true},
+		{false, "https://client.example.com/cb?a=b", "https://client.example.com/cb?a=c", false},
+		{false, "http://127.0.0.1/cb", "http://127.0.0.1:51004/cb", true},
+		{false, "http://127.0.0.1:8080/cb", "http://127.0.0.1:51004/cb", true},
+		{false, "http://[::1]/cb", "http://[::1]:51004/cb", true},
+		{false, "http://127.0.0.1/cb", "http://[::1]:51004/cb", false},
+		{false, "http://127.0.0.1/cb", "http://127.0.0.1:51004/other", false},
+		{false, "http://localhost/cb", "http://localhost:51004/cb", false},
+		{false, "https://127.0.0.1/cb", "https://127.0.0.1:51004/cb", false},
+		{false, "com.example.app:/oauth2redirect", "com.example.app:/oauth2redirect", true},
+		{false, "myapp:/oauth2redirect", "myapp:/oauth2redirect", false},
+		{false, "ftp://client.example.com/cb", "ftp://client.example.com/cb", false},
+		{false, "https://client.example.com/cb#frag", "https://client.example.com/cb#frag", false},
+		{false, "https://client.example.com/cb", "https://client.example.com/cb#", false},
+		{false, "javascript:alert(1)", "javascript:alert(1)", false},
+		{false, "data:text/html,hi", "data:text/html,hi", false},
+		{false, "/cb", "/cb", false},
+		{true, "https://client.example.com/cb", "https://client.example.com/cb", true},
+		{true, "http://127.0.0.1:8080/cb", "http://127.0.0.1:8080/cb", true},
+		{true, "http://127.0.0.1/cb", "http://127.0.0.1:51004/cb", false},
+		{true, "http://client.example.com/cb", "http://client.example.com/cb", false},
+		{true, "com.example.app:/oauth2redirect", "com.example.app:/oauth2redirect", true},
+		{true, "myapp:/oauth2redirect", "myapp:/oauth2redirect", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.uri, func(t *testing.T) {
+			t.Parallel()
+
+			m := &RedirectURIMatcher{Strict: tt.strict}
+			if actual := m.Match([]string{tt.registered}, tt.uri); actual != tt.expected {
+				t.Errorf("Match(%v, %v, %v) => %v, expected %v", tt.strict, tt.registered, tt.uri, actual, tt.expected)
+			}
+		})
+	}
+}
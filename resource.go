@@ -0,0 +1,51 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"net/url"
+)
+
+// ResourceClient is a client restricted to a set of resource servers.
+// Requests with a resource parameter are rejected for clients not
+// implementing it.
+//
+// https://tools.ietf.org/html/rfc8707
+type ResourceClient interface {
+	Client
+	IsAllowedResource(resource string) bool
+}
+
+// ResourcesFromContext returns the target resources of the request, if any.
+// Services SHOULD audience-restrict issued access tokens to them.
+//
+// https://tools.ietf.org/html/rfc8707#section-2
+func ResourcesFromContext(ctx context.Context) []string {
+	resources, _ := ctx.Value(resourcesKey).([]string)
+	return resources
+}
+
+func validateResources(resources []string, client Client) error {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	rc, ok := client.(ResourceClient)
+	if !ok {
+		return ErrInvalidTarget
+	}
+
+	for _, resource := range resources {
+		u, err := url.Parse(resource)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return ErrInvalidTarget
+		}
+
+		if !rc.IsAllowedResource(resource) {
+			return ErrInvalidTarget
+		}
+	}
+
+	return nil
+}
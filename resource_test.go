@@ -0,0 +1,47 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import "testing"
+
+type testResourceClient struct {
+	testClient
+	resources []string
+}
+
+func (c *testResourceClient) IsAllowedResource(resource string) bool {
+	return containsString(c.resources, resource)
+}
+
+func TestValidateResources(t *testing.T) {
+	client := &testResourceClient{
+		testClient: testClient{id: "foo"},
+		resources:  []string{"https://api.example.com/", "https://pay.example.com/"},
+	}
+
+	tests := []struct {
+		name      string
+		client    Client
+		resources []string
+		expected  error
+	}{
+		{"None", client, nil, nil},
+		{"Allowed", client, []string{"https://api.example.com/"}, nil},
+		{"AllowedMultiple", client, []string{"https://api.example.com/", "https://pay.example.com/"}, nil},
+		{"NotAllowed", client, []string{"https://other.example.com/"}, ErrInvalidTarget},
+		{"Relative", client, []string{"/api"}, ErrInvalidTarget},
+		{"Fragment", client, []string{"https://api.example.com/#foo"}, ErrInvalidTarget},
+		{"NoResourceClient", &testClient{id: "foo"}, []string{"https://api.example.com/"}, ErrInvalidTarget},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := validateResources(tt.resources, tt.client)
+			if got != tt.expected {
+				t.Errorf("validateResources(%v) => %v, expected %v", tt.resources, got, tt.expected)
+			}
+		})
+	}
+}
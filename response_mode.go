@@ -0,0 +1,250 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Response modes define how authorization response parameters are
+// returned to the client.
+//
+// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+// https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+const (
+	ResponseModeQuery       = "query"
+	ResponseModeFragment    = "fragment"
+	ResponseModeFormPost    = "form_post"
+	ResponseModeJWT         = "jwt"
+	ResponseModeQueryJWT    = "query.jwt"
+	ResponseModeFragmentJWT = "fragment.jwt"
+	ResponseModeFormPostJWT = "form_post.jwt"
+)
+
+// ResponseEncrypter encrypts JWT secured authorization responses for
+// the client. It returns the signed response unchanged if the client
+// did not register for encrypted responses.
+//
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.2
+type ResponseEncrypter interface {
+	EncryptResponse(ctx context.Context, client Client, signed string) (string, error)
+}
+
+// jarmLifetime is the lifetime of JWT secured authorization responses.
+// They are consumed immediately, so it is kept short.
+const jarmLifetime = 10 * time.Minute
+
+type responder struct {
+	mode      string
+	issuer    string
+	client    Client
+	signer    JWTSigner
+	encrypter ResponseEncrypter
+	tracer    Tracer
+	logger    Log
+
+	// span is the span of the authorize grant type, if it responds.
+	span Span
+}
+
+// newResponder creates a responder for the client with the handler's
+// issuer, signer and encrypter.
+func (h *Handler) newResponder(mode string, client Client) *responder {
+	return &responder{
+		mode:      mode,
+		issuer:    h.Issuer,
+		client:    client,
+		signer:    h.ResponseSigner,
+		encrypter: h.ResponseEncrypter,
+		tracer:    h.tracer(),
+		logger:    h.logger,
+	}
+}
+
+func responderFromContext(ctx context.Context) *responder {
+	if r, ok := ctx.Value(responderKey).(*responder); ok {
+		return r
+	}
+	return &responder{mode: ResponseModeFragment, tracer: NoopTracer, logger: log.New(ioutil.Discard, "", 0)}
+}
+
+// recordError records the error of the authorization response on the
+// span of the grant type, if any.
+func (r *responder) recordError(err error) {
+	if r.span != nil {
+		r.span.SetAttributes(SpanAttribute{AttributeError, err.Error()})
+		r.span.RecordError(err)
+	}
+}
+
+func isJWTResponseMode(mode string) bool {
+	return mode == ResponseModeJWT || strings.HasSuffix(mode, ".jwt")
+}
+
+// resolveResponseMode validates the requested response mode and expands
+// it to the concrete mode used for the grant type.
+//
+// Response types issuing tokens in the authorization response default
+// to the fragment encoding and MUST NOT use the query encoding. The jwt
+// response modes require a signer and an issuer, as the client
+// validates the iss claim of the response.
+//
+// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#Combinations
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.3.1
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.4
+func resolveResponseMode(mode string, grantType AuthorizeGrantType, signer JWTSigner, issuer string) (string, error) {
+	defaultMode := defaultResponseMode(grantType)
+	if mode == "" {
+		return defaultMode, nil
+	}
+
+	if mode == ResponseModeJWT {
+		mode = defaultMode + ".jwt"
+	}
+
+	if isJWTResponseMode(mode) && (signer == nil || issuer == "") {
+		return "", ErrInvalidRequest
+	}
+
+	switch mode {
+	case ResponseModeQuery, ResponseModeQueryJWT:
+		if defaultMode != ResponseModeQuery {
+			return "", ErrInvalidRequest
+		}
+		return mode, nil
+	case ResponseModeFragment, ResponseModeFragmentJWT, ResponseModeFormPost, ResponseModeFormPostJWT:
+		return mode, nil
+	}
+
+	return "", ErrInvalidRequest
+}
+
+func defaultResponseMode(grantType AuthorizeGrantType) string {
+	if rgt, ok := grantType.(ResponseModeGrantType); ok {
+		return rgt.DefaultResponseMode()
+	}
+	return ResponseModeQuery
+}
+
+func (r *responder) redirect(w http.ResponseWriter, req *http.Request, redirectURI string, values url.Values) {
+	_, span := r.tracer.Start(req.Context(), "oauth2.WriteResponse",
+		SpanAttribute{AttributeEndpoint, "authorize"},
+		SpanAttribute{AttributeResponseMode, r.mode},
+	)
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	mode := r.mode
+	if isJWTResponseMode(mode) {
+		// The iss claim of the response JWT takes the place of the iss
+		// parameter.
+		//
+		// https://tools.ietf.org/html/rfc9207#section-2.4
+		var response string
+		response, err = r.secure(req.Context(), values)
+		if err != nil {
+			writeError(w, r.logger, http.StatusInternalServerError, ErrServerError, values.Get("state"))
+			return
+		}
+
+		values = url.Values{}
+		values.Set("response", response)
+		mode = strings.TrimSuffix(mode, ".jwt")
+	} else if r.issuer != "" {
+		values.Set("iss", r.issuer)
+	}
+
+	switch mode {
+	case ResponseModeQuery:
+		var u *url.URL
+		u, err = url.Parse(redirectURI)
+		if err != nil {
+			writeError(w, r.logger, http.StatusBadRequest, ErrInvalidRequest, values.Get("state"))
+			return
+		}
+		query := u.Query()
+		for k, vs := range values {
+			query[k] = vs
+		}
+		u.RawQuery = query.Encode()
+		http.Redirect(w, req, u.String(), http.StatusFound)
+	case ResponseModeFormPost:
+		writeFormPost(w, r.logger, redirectURI, values)
+	default:
+		http.Redirect(w, req, redirectURI+"#"+values.Encode(), http.StatusFound)
+	}
+}
+
+// secure wraps the response parameters in a signed and optionally
+// encrypted JWT.
+//
+// https://openid.net/specs/oauth-v2-jarm.html#section-2.1
+func (r *responder) secure(ctx context.Context, values url.Values) (string, error) {
+	claims := make(map[string]interface{}, len(values)+3)
+	for k, vs := range values {
+		if len(vs) == 1 {
+			claims[k] = vs[0]
+		} else {
+			claims[k] = vs
+		}
+	}
+
+	claims["iss"] = r.issuer
+	claims["aud"] = r.client.Identifier()
+	claims["exp"] = time.Now().Add(jarmLifetime).Unix()
+
+	response, err := signJWT(r.signer, claims)
+	if err != nil {
+		r.logger.Println(err)
+		return "", err
+	}
+
+	if r.encrypter != nil {
+		response, err = r.encrypter.EncryptResponse(ctx, r.client, response)
+		if err != nil {
+			r.logger.Println(err)
+			return "", err
+		}
+	}
+
+	return response, nil
+}
+
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Submit This Form</title></head>
+<body onload="javascript:document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{- range $name, $values := .Values}}{{range $values}}
+<input type="hidden" name="{{$name}}" value="{{.}}"/>
+{{- end}}{{end}}
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+func writeFormPost(w http.ResponseWriter, logger Log, action string, values url.Values) {
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Pragma", "no-cache")
+
+	w.WriteHeader(http.StatusOK)
+
+	err := formPostTemplate.Execute(w, struct {
+		Action string
+		Values url.Values
+	}{action, values})
+	if err != nil {
+		logger.Println(err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testCodeGT struct {
+	AuthorizeGrantType
+}
+
+func TestResolveResponseMode(t *testing.T) {
+	implicit := NewImplicitGrantType(nil, nil).(AuthorizeGrantType)
+	code := testCodeGT{}
+	signer := NewHMACSigner("", []byte("secret"))
+	const issuer = "https://server.example.com"
+
+	tests := []struct {
+		mode      string
+		grantType AuthorizeGrantType
+		signer    JWTSigner
+		issuer    string
+		expected  string
+		err       error
+	}{
+		{"", implicit, nil, "", ResponseModeFragment, nil},
+		{"", code, nil, "", ResponseModeQuery, nil},
+		{ResponseModeQuery, code, nil, "", ResponseModeQuery, nil},
+		{ResponseModeQuery, implicit, nil, "", "", ErrInvalidRequest},
+		{ResponseModeFragment, code, nil, "", ResponseModeFragment, nil},
+		{ResponseModeFormPost, implicit, nil, "", ResponseModeFormPost, nil},
+		{ResponseModeJWT, implicit, signer, issuer, ResponseModeFragmentJWT, nil},
+		{ResponseModeJWT, code, signer, issuer, ResponseModeQueryJWT, nil},
+		{ResponseModeJWT, code, nil, "", "", ErrInvalidRequest},
+		{ResponseModeJWT, code, signer, "", "", ErrInvalidRequest},
+		{ResponseModeQueryJWT, implicit, signer, issuer, "", ErrInvalidRequest},
+		{ResponseModeFormPostJWT, implicit, signer, issuer, ResponseModeFormPostJWT, nil},
+		{"foo", implicit, signer, issuer, "", ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveResponseMode(tt.mode, tt.grantType, tt.signer, tt.issuer)
+		if got != tt.expected || err != tt.err {
+			t.Errorf("resolveResponseMode(%q, %T) => %q, %v, expected %q, %v", tt.mode, tt.grantType, got, err, tt.expected, tt.err)
+		}
+	}
+}
+
+func TestResponderRedirect(t *testing.T) {
+	values := url.Values{}
+	values.Set("code", "foo")
+	values.Set("state", "bar")
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{ResponseModeQuery, "https://client.example.com/cb?code=foo&state=bar&x=y"},
+		{ResponseModeFragment, "https://client.example.com/cb?x=y#code=foo&state=bar"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+		r := responderFromContext(req.Context())
+		r.mode = tt.mode
+		r.redirect(w, req, "https://client.example.com/cb?x=y", values)
+
+		if got := w.Header().Get("Location"); got != tt.expected {
+			t.Errorf("redirect(%s) => %s, expected %s", tt.mode, got, tt.expected)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+	r := responderFromContext(req.Context())
+	r.issuer = "https://as.example.com"
+	r.redirect(w, req, "https://client.example.com/cb", url.Values{"error": {"access_denied"}})
+
+	expected := "https://client.example.com/cb#error=access_denied&iss=https%3A%2F%2Fas.example.com"
+	if got := w.Header().Get("Location"); got != expected {
+		t.Errorf("redirect with issuer => %s, expected %s", got, expected)
+	}
+
+	w = httptest.NewRecorder()
+	r = responderFromContext(req.Context())
+	r.mode = ResponseModeFormPost
+	r.redirect(w, req, "https://client.example.com/cb", values)
+
+	body := w.Body.String()
+	if !strings.Contains(body, `action="https://client.example.com/cb"`) || !strings.Contains(body, `name="code" value="foo"`) {
+		t.Errorf("redirect(%s) => %s, expected auto-submitting form", ResponseModeFormPost, body)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Revoke is used by the client to notify the authorization server that
+// a previously obtained refresh or access token is no longer needed.
+// Revoking a refresh token also revokes the tokens of its family. It
+// responds with 404 Not Found if the handler has no TokenStore.
+//
+// https://tools.ietf.org/html/rfc7009#section-2
+func (h *Handler) Revoke(w http.ResponseWriter, req *http.Request) {
+	if h.TokenStore == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	req = h.withEvents(req, EventRevocationRefused)
+
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.refuse(w, req, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+		return
+	}
+
+	value := req.PostFormValue("token")
+	if value == "" {
+		h.refuse(w, req, http.StatusBadRequest, ErrInvalidRequest, "")
+		return
+	}
+
+	describeRequest(req)
+
+	client, req, err := h.authenticateClient(req)
+	if errors.Is(err, ErrInvalidClient) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		h.refuse(w, req, http.StatusUnauthorized, err, "")
+		return
+	} else if errors.Is(err, ErrServerError) {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	} else if err != nil {
+		h.refuse(w, req, http.StatusBadRequest, err, "")
+		return
+	}
+
+	token, err := h.TokenStore.FindToken(req.Context(), value)
+	if err != nil {
+		h.refuse(w, req, http.StatusInternalServerError, err, "")
+		return
+	}
+
+	// Invalid tokens and tokens of other clients do not cause an error
+	// response, as the client cannot handle it in a reasonable way.
+	//
+	// https://tools.ietf.org/html/rfc7009#section-2.2
+	if token != nil && token.ClientID == client.Identifier() {
+		if token.Kind == RefreshTokenKind && token.Family != "" {
+			err = h.TokenStore.RevokeFamily(req.Context(), token.Family)
+		} else {
+			err = h.TokenStore.RevokeToken(req.Context(), token.Value)
+		}
+		if err != nil {
+			h.refuse(w, req, http.StatusServiceUnavailable, err, "")
+			return
+		}
+
+		emitEvent(req.Context(), &Event{Type: EventTokenRevoked, Subject: token.Subject, Scopes: token.Scopes})
+	}
+
+	writeJSON(w, h.logger, http.StatusOK, nil, map[string]string{
+		"Cache-Control": "no-store",
+		"Pragma":        "no-cache",
+	})
+}
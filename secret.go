@@ -0,0 +1,508 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SecretHasher hashes client secrets for storage and compares secrets
+// with hashes in its format.
+//
+// Client secrets are generated with high entropy, so guessing them is
+// infeasible even with a cheap hash. The default cost parameters are
+// therefore low, as every client authentication pays them. Hashes whose
+// cost parameters exceed fixed limits are rejected, so that a stored
+// hash cannot make authentication arbitrarily expensive.
+type SecretHasher interface {
+	// Identifies reports whether the hash is in the format of the hasher.
+	Identifies(hashed string) bool
+	// Hash hashes the secret with a random salt.
+	Hash(secret string) (string, error)
+	// Validate returns an error if the hash is malformed or its cost
+	// parameters exceed the limits of the hasher.
+	Validate(hashed string) error
+	// Compare reports whether the secret matches the hash, in constant
+	// time.
+	Compare(hashed, secret string) bool
+}
+
+// ClientSecret is one of possibly several active secrets of a client.
+// Clients rotate secrets without downtime by adding a new secret before
+// the old one expires.
+type ClientSecret struct {
+	// ID identifies the secret for auditing.
+	ID string
+	// Hash is the hashed secret.
+	Hash string
+	// ExpiresAt is the time the secret expires, or zero if it does not.
+	ExpiresAt time.Time
+}
+
+// MultiSecretClient is a client with several active secrets. The handler
+// authenticates it with its SecretVerifier instead of Authenticate.
+type MultiSecretClient interface {
+	Client
+	ClientSecrets() []ClientSecret
+}
+
+// ClientSecretFromContext returns the secret the client authenticated
+// with, if it is a MultiSecretClient.
+func ClientSecretFromContext(ctx context.Context) *ClientSecret {
+	secret, _ := ctx.Value(clientSecretKey).(*ClientSecret)
+	return secret
+}
+
+// SecretVerifier verifies client secrets against hashes in the formats
+// of its hashers. PBKDF2, scrypt, bcrypt and argon2id hashes are always
+// supported.
+type SecretVerifier struct {
+	hashers []SecretHasher
+}
+
+// NewSecretVerifier creates a new secret verifier. New hashes are created
+// with the first hasher, or PBKDF2 if none is given. PBKDF2, scrypt,
+// bcrypt and argon2id hashes with the default cost parameters are always
+// supported.
+func NewSecretVerifier(hashers ...SecretHasher) *SecretVerifier {
+	hashers = append(hashers,
+		NewPBKDF2Hasher(pbkdf2Iterations),
+		NewScryptHasher(scryptN, scryptR, scryptP),
+		NewBcryptHasher(bcryptCost),
+		NewArgon2Hasher(argon2Passes, argon2Memory, argon2Threads),
+	)
+	return &SecretVerifier{hashers}
+}
+
+// DefaultSecretVerifier creates PBKDF2 hashes and verifies PBKDF2,
+// scrypt, bcrypt and argon2id hashes.
+var DefaultSecretVerifier = NewSecretVerifier()
+
+// Hash hashes the secret with the first hasher.
+func (v *SecretVerifier) Hash(secret string) (string, error) {
+	return v.hashers[0].Hash(secret)
+}
+
+// Validate returns an error if the hash is in no supported format, is
+// malformed or its cost parameters exceed the limits of its hasher.
+func (v *SecretVerifier) Validate(hashed string) error {
+	for _, h := range v.hashers {
+		if h.Identifies(hashed) {
+			return h.Validate(hashed)
+		}
+	}
+	return errUnsupportedSecretHash
+}
+
+// Compare reports whether the secret matches the hash.
+func (v *SecretVerifier) Compare(hashed, secret string) bool {
+	for _, h := range v.hashers {
+		if h.Identifies(hashed) {
+			return h.Compare(hashed, secret)
+		}
+	}
+	return false
+}
+
+// Verify compares the secret with all unexpired secrets and returns the
+// one that matched, or nil. All secrets are compared, so the time taken
+// does not reveal which one matched.
+func (v *SecretVerifier) Verify(secrets []ClientSecret, secret string, now time.Time) *ClientSecret {
+	var matched *ClientSecret
+	for i := range secrets {
+		s := &secrets[i]
+		if !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt) {
+			continue
+		}
+		if v.Compare(s.Hash, secret) && matched == nil {
+			matched = s
+		}
+	}
+	return matched
+}
+
+// HashSecret hashes a client secret for storage with the
+// DefaultSecretVerifier.
+func HashSecret(secret string) (string, error) {
+	return DefaultSecretVerifier.Hash(secret)
+}
+
+// CompareSecret reports whether the secret matches the hash, in constant
+// time, using the DefaultSecretVerifier.
+func CompareSecret(hashed, secret string) bool {
+	return DefaultSecretVerifier.Compare(hashed, secret)
+}
+
+var (
+	errUnsupportedSecretHash = errors.New("oauth2: unsupported secret hash format")
+	errMalformedSecretHash   = errors.New("oauth2: malformed secret hash")
+	errSecretHashCost        = errors.New("oauth2: secret hash cost parameters exceed limits")
+)
+
+// PBKDF2 iteration count for new hashes, and the limit for compared ones.
+const (
+	pbkdf2Iterations    = 10000
+	maxPBKDF2Iterations = 1 << 20
+)
+
+// NewPBKDF2Hasher creates a new hasher using PBKDF2 with HMAC-SHA256. It
+// also compares PBKDF2 hashes using HMAC-SHA512. Hashes have the format
+// pbkdf2-sha256$iterations$salt$key.
+//
+// https://tools.ietf.org/html/rfc8018#section-5.2
+func NewPBKDF2Hasher(iterations int) SecretHasher {
+	return &pbkdf2Hasher{iterations}
+}
+
+type pbkdf2Hasher struct {
+	iterations int
+}
+
+var pbkdf2Hashes = map[string]func() hash.Hash{
+	"pbkdf2-sha256": sha256.New,
+	"pbkdf2-sha512": sha512.New,
+}
+
+func (h *pbkdf2Hasher) Identifies(hashed string) bool {
+	i := strings.IndexByte(hashed, '$')
+	if i < 0 {
+		return false
+	}
+	_, ok := pbkdf2Hashes[hashed[:i]]
+	return ok
+}
+
+func (h *pbkdf2Hasher) Hash(secret string) (string, error) {
+	if h.iterations <= 0 || h.iterations > maxPBKDF2Iterations {
+		return "", errSecretHashCost
+	}
+
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key([]byte(secret), salt, h.iterations, sha256.Size, sha256.New)
+
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", h.iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func (h *pbkdf2Hasher) Validate(hashed string) error {
+	_, _, _, _, err := parsePBKDF2(hashed)
+	return err
+}
+
+func (h *pbkdf2Hasher) Compare(hashed, secret string) bool {
+	prf, iterations, salt, expected, err := parsePBKDF2(hashed)
+	if err != nil {
+		return false
+	}
+
+	key := pbkdf2.Key([]byte(secret), salt, iterations, len(expected), prf)
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+func parsePBKDF2(hashed string) (func() hash.Hash, int, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 4 {
+		return nil, 0, nil, nil, errMalformedSecretHash
+	}
+
+	prf, ok := pbkdf2Hashes[parts[0]]
+	if !ok {
+		return nil, 0, nil, nil, errUnsupportedSecretHash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return nil, 0, nil, nil, errMalformedSecretHash
+	}
+	if iterations > maxPBKDF2Iterations {
+		return nil, 0, nil, nil, errSecretHashCost
+	}
+
+	salt, key, err := decodeSaltAndKey(parts[2], parts[3])
+	if err != nil {
+		return nil, 0, nil, nil, err
+	}
+	return prf, iterations, salt, key, nil
+}
+
+// scrypt parameters for new hashes, and the limits for compared ones.
+const (
+	scryptN, scryptR, scryptP             = 1 << 12, 8, 1
+	maxScryptLogN, maxScryptR, maxScryptP = 16, 16, 4
+)
+
+// NewScryptHasher creates a new hasher using scrypt with the CPU/memory
+// cost n, block size r and parallelization p. Hashes have the format
+// $scrypt$ln=log2(n),r=r,p=p$salt$key.
+//
+// https://tools.ietf.org/html/rfc7914
+func NewScryptHasher(n, r, p int) SecretHasher {
+	return &scryptHasher{n, r, p}
+}
+
+type scryptHasher struct {
+	n, r, p int
+}
+
+func (h *scryptHasher) Identifies(hashed string) bool {
+	return strings.HasPrefix(hashed, "$scrypt$")
+}
+
+func (h *scryptHasher) Hash(secret string) (string, error) {
+	ln := bits.Len(uint(h.n)) - 1
+	if h.n <= 1 || h.n != 1<<uint(ln) {
+		return "", errors.New("oauth2: scrypt cost must be a power of two greater than 1")
+	}
+	if err := checkScryptParams(ln, h.r, h.p); err != nil {
+		return "", err
+	}
+
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(secret), salt, h.n, h.r, h.p, 32)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", ln, h.r, h.p, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func (h *scryptHasher) Validate(hashed string) error {
+	_, _, _, _, _, err := parseScrypt(hashed)
+	return err
+}
+
+func (h *scryptHasher) Compare(hashed, secret string) bool {
+	ln, r, p, salt, expected, err := parseScrypt(hashed)
+	if err != nil {
+		return false
+	}
+
+	key, err := scrypt.Key([]byte(secret), salt, 1<<uint(ln), r, p, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+func parseScrypt(hashed string) (int, int, int, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "scrypt" {
+		return 0, 0, 0, nil, nil, errMalformedSecretHash
+	}
+
+	var ln, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil {
+		return 0, 0, 0, nil, nil, errMalformedSecretHash
+	}
+	if err := checkScryptParams(ln, r, p); err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+
+	salt, key, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+	return ln, r, p, salt, key, nil
+}
+
+func checkScryptParams(ln, r, p int) error {
+	if ln <= 0 || r <= 0 || p <= 0 {
+		return errMalformedSecretHash
+	}
+	if ln > maxScryptLogN || r > maxScryptR || p > maxScryptP {
+		return errSecretHashCost
+	}
+	return nil
+}
+
+// bcrypt cost for new hashes, and the limit for compared ones.
+const (
+	bcryptCost    = bcrypt.DefaultCost
+	maxBcryptCost = 14
+)
+
+// NewBcryptHasher creates a new hasher using bcrypt with the cost. Hashes
+// have the modular crypt format $2a$cost$saltkey; secrets longer than 72
+// bytes cannot be hashed.
+func NewBcryptHasher(cost int) SecretHasher {
+	return &bcryptHasher{cost}
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) Identifies(hashed string) bool {
+	return strings.HasPrefix(hashed, "$2a$") || strings.HasPrefix(hashed, "$2b$") || strings.HasPrefix(hashed, "$2y$")
+}
+
+func (h *bcryptHasher) Hash(secret string) (string, error) {
+	if h.cost > maxBcryptCost {
+		return "", errSecretHashCost
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *bcryptHasher) Validate(hashed string) error {
+	cost, err := bcrypt.Cost([]byte(hashed))
+	if err != nil {
+		return errMalformedSecretHash
+	}
+	if cost > maxBcryptCost {
+		return errSecretHashCost
+	}
+	return nil
+}
+
+func (h *bcryptHasher) Compare(hashed, secret string) bool {
+	if h.Validate(hashed) != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(secret)) == nil
+}
+
+// argon2id parameters for new hashes, and the limits for compared ones.
+// The memory is given in KiB.
+const (
+	argon2Passes, argon2Memory, argon2Threads          = 1, 16 * 1024, 1
+	maxArgon2Passes, maxArgon2Memory, maxArgon2Threads = 10, 256 * 1024, 16
+)
+
+// NewArgon2Hasher creates a new hasher using argon2id with the number of
+// passes over the memory, the memory in KiB and the number of threads.
+// Hashes have the format $argon2id$v=19$m=memory,t=passes,p=threads$salt$key.
+//
+// https://tools.ietf.org/html/rfc9106
+func NewArgon2Hasher(passes, memory uint32, threads uint8) SecretHasher {
+	return &argon2Hasher{passes, memory, threads}
+}
+
+type argon2Hasher struct {
+	passes  uint32
+	memory  uint32
+	threads uint8
+}
+
+func (h *argon2Hasher) Identifies(hashed string) bool {
+	return strings.HasPrefix(hashed, "$argon2id$")
+}
+
+func (h *argon2Hasher) Hash(secret string) (string, error) {
+	if err := checkArgon2Params(h.passes, h.memory, h.threads); err != nil {
+		return "", err
+	}
+
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(secret), salt, h.passes, h.memory, h.threads, 32)
+
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.passes, h.threads, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func (h *argon2Hasher) Validate(hashed string) error {
+	_, _, _, _, _, err := parseArgon2(hashed)
+	return err
+}
+
+func (h *argon2Hasher) Compare(hashed, secret string) bool {
+	passes, memory, threads, salt, expected, err := parseArgon2(hashed)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(secret), salt, passes, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+func parseArgon2(hashed string) (uint32, uint32, uint8, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return 0, 0, 0, nil, nil, errMalformedSecretHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return 0, 0, 0, nil, nil, errUnsupportedSecretHash
+	}
+
+	var passes, memory uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil {
+		return 0, 0, 0, nil, nil, errMalformedSecretHash
+	}
+	if err := checkArgon2Params(passes, memory, threads); err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+
+	salt, key, err := decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+	return passes, memory, threads, salt, key, nil
+}
+
+func checkArgon2Params(passes, memory uint32, threads uint8) error {
+	if passes == 0 || threads == 0 || memory < 8*uint32(threads) {
+		return errMalformedSecretHash
+	}
+	if passes > maxArgon2Passes || memory > maxArgon2Memory || threads > maxArgon2Threads {
+		return errSecretHashCost
+	}
+	return nil
+}
+
+func randomSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func decodeSaltAndKey(encodedSalt, encodedKey string) ([]byte, []byte, error) {
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, nil, errMalformedSecretHash
+	}
+	key, err := enc.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, nil, errMalformedSecretHash
+	}
+	return salt, key, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecretHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher SecretHasher
+	}{
+		{"pbkdf2", NewPBKDF2Hasher(1000)},
+		{"scrypt", NewScryptHasher(16, 1, 1)},
+		{"bcrypt", NewBcryptHasher(4)},
+		{"argon2", NewArgon2Hasher(1, 64, 1)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			hashed, err := tt.hasher.Hash("secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.hasher.Identifies(hashed) || tt.hasher.Validate(hashed) != nil {
+				t.Errorf("Validate(%s) => %v, expected nil", hashed, tt.hasher.Validate(hashed))
+			}
+			if !DefaultSecretVerifier.Compare(hashed, "secret") {
+				t.Errorf("Compare(%s, secret) => false, expected true", hashed)
+			}
+			if DefaultSecretVerifier.Compare(hashed, "wrong") {
+				t.Errorf("Compare(%s, wrong) => true, expected false", hashed)
+			}
+		})
+	}
+}
+
+func TestSecretVerifierValidate(t *testing.T) {
+	tests := []struct {
+		hashed   string
+		expected error
+	}{
+		{"pbkdf2-sha256$2000000$c2FsdA$a2V5", errSecretHashCost},
+		{"pbkdf2-sha256$0$c2FsdA$a2V5", errMalformedSecretHash},
+		{"pbkdf2-sha256$1000$c2FsdA", errMalformedSecretHash},
+		{"$scrypt$ln=31,r=8,p=1$c2FsdA$a2V5", errSecretHashCost},
+		{"$scrypt$ln=14,r=1024,p=1$c2FsdA$a2V5", errSecretHashCost},
+		{"$scrypt$ln=14,r=8,p=1024$c2FsdA$a2V5", errSecretHashCost},
+		{"$scrypt$ln=14,r=8,p=1$c2FsdA$", errMalformedSecretHash},
+		{"$2a$31$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyzabcde", errSecretHashCost},
+		{"$2a$10$short", errMalformedSecretHash},
+		{"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5", errSecretHashCost},
+		{"$argon2id$v=19$m=65536,t=100,p=1$c2FsdA$a2V5", errSecretHashCost},
+		{"$argon2id$v=16$m=65536,t=1,p=1$c2FsdA$a2V5", errUnsupportedSecretHash},
+		{"plain", errUnsupportedSecretHash},
+	}
+
+	for _, tt := range tests {
+		if err := DefaultSecretVerifier.Validate(tt.hashed); err != tt.expected {
+			t.Errorf("Validate(%s) => %v, expected %v", tt.hashed, err, tt.expected)
+		}
+		if DefaultSecretVerifier.Compare(tt.hashed, "secret") {
+			t.Errorf("Compare(%s, secret) => true, expected false", tt.hashed)
+		}
+	}
+}
+
+func TestSecretVerifierVerify(t *testing.T) {
+	verifier := NewSecretVerifier(NewScryptHasher(16, 1, 1))
+	now := time.Now()
+
+	hash := func(secret string) string {
+		hashed, err := verifier.Hash(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashed
+	}
+
+	pbkdf2, err := NewPBKDF2Hasher(1).Hash("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := []ClientSecret{
+		{ID: "expired", Hash: hash("expired"), ExpiresAt: now},
+		{ID: "old", Hash: hash("old"), ExpiresAt: now.Add(time.Hour)},
+		{ID: "new", Hash: pbkdf2},
+	}
+
+	tests := []struct {
+		secret   string
+		expected string
+	}{
+		{"old", "old"},
+		{"new", "new"},
+		{"expired", ""},
+		{"wrong", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if secret := verifier.Verify(secrets, tt.secret, now); secret != nil {
+			got = secret.ID
+		}
+		if got != tt.expected {
+			t.Errorf("Verify(%s) => %q, expected %q", tt.secret, got, tt.expected)
+		}
+	}
+}
+
+func TestHashSecret(t *testing.T) {
+	hashed, err := HashSecret("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !CompareSecret(hashed, "foo") {
+		t.Errorf("CompareSecret(%s, foo) => false, expected true", hashed)
+	}
+	if CompareSecret(hashed, "bar") {
+		t.Errorf("CompareSecret(%s, bar) => true, expected false", hashed)
+	}
+	if CompareSecret("foo", "foo") {
+		t.Errorf("CompareSecret(foo, foo) => true, expected false")
+	}
+}
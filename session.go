@@ -0,0 +1,257 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Session is an authenticated session of a resource owner.
+type Session struct {
+	// ID identifies the session, e.g. as OpenID Connect sid.
+	ID string
+	// Subject identifies the resource owner.
+	Subject string
+	// AuthTime is the time the resource owner authenticated.
+	AuthTime time.Time
+}
+
+// Authenticator authenticates resource owners, e.g. through session
+// cookies.
+type Authenticator interface {
+	// Session returns the session of the resource owner making the
+	// request, or nil if the resource owner is not authenticated.
+	Session(req *http.Request) (*Session, error)
+}
+
+// RequestStore parks authorization requests while the resource owner
+// authenticates.
+type RequestStore interface {
+	// Park stores the authorization request parameters and returns an
+	// identifier to resume them.
+	Park(ctx context.Context, params url.Values) (string, error)
+	// Resume returns and removes the parked authorization request
+	// parameters, or nil if they are unknown or expired.
+	Resume(ctx context.Context, id string) (url.Values, error)
+}
+
+// SessionFromContext returns the session of the authenticated resource
+// owner, if the handler has an Authenticator.
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey).(*Session)
+	return session
+}
+
+// NewMemoryRequestStore creates a new request store holding parked
+// requests in memory for the given lifetime.
+func NewMemoryRequestStore(lifetime time.Duration) RequestStore {
+	return &memoryRequestStore{
+		lifetime: lifetime,
+		requests: make(map[string]parkedRequest),
+	}
+}
+
+var _ RequestStore = (*memoryRequestStore)(nil)
+
+type parkedRequest struct {
+	params  url.Values
+	expires time.Time
+}
+
+type memoryRequestStore struct {
+	lifetime time.Duration
+	mu       sync.Mutex
+	requests map[string]parkedRequest
+}
+
+func (s *memoryRequestStore) Park(ctx context.Context, params url.Values) (string, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, r := range s.requests {
+		if now.After(r.expires) {
+			delete(s.requests, k)
+		}
+	}
+	s.requests[id] = parkedRequest{params, now.Add(s.lifetime)}
+
+	return id, nil
+}
+
+func (s *memoryRequestStore) Resume(ctx context.Context, id string) (url.Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.requests[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.requests, id)
+
+	if time.Now().After(r.expires) {
+		return nil, nil
+	}
+	return r.params, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// parkedAtParam records when an authorization request was parked. It is
+// set when parking and removed when resuming, so clients cannot forge it.
+const parkedAtParam = "parked_at"
+
+// resumeRequest replaces the request parameters with the parked ones
+// if the request resumes an authorization request after login. It
+// returns when the request was parked, or the zero time if the request
+// does not resume one.
+func (h *Handler) resumeRequest(req *http.Request) (time.Time, error) {
+	id := req.FormValue("resume")
+	if id == "" || h.Authenticator == nil {
+		return time.Time{}, nil
+	}
+
+	params, err := h.RequestStore.Resume(req.Context(), id)
+	if err != nil {
+		return time.Time{}, ErrServerError
+	}
+	if params == nil {
+		return time.Time{}, ErrInvalidRequest
+	}
+
+	parkedAt, err := strconv.ParseInt(params.Get(parkedAtParam), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidRequest
+	}
+	params.Del(parkedAtParam)
+
+	req.Form = params
+	return time.Unix(parkedAt, 0), nil
+}
+
+// authenticate ensures the resource owner is authenticated according to
+// the prompt and max_age parameters, redirecting to the login page if
+// needed. parkedAt is the time a resumed request was parked, or the zero
+// time. It returns nil if a response has been written.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (h *Handler) authenticate(w http.ResponseWriter, req *http.Request, redirectURI, state string, prompt []string, parkedAt time.Time) *Session {
+	session, err := h.Authenticator.Session(req)
+	if err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil
+	}
+
+	if containsString(prompt, "none") && len(prompt) > 1 {
+		redirectWithError(w, req, redirectURI, state, ErrInvalidRequest)
+		return nil
+	}
+
+	maxAge := -1
+	if v := req.FormValue("max_age"); v != "" {
+		maxAge, err = strconv.Atoi(v)
+		if err != nil || maxAge < 0 {
+			redirectWithError(w, req, redirectURI, state, ErrInvalidRequest)
+			return nil
+		}
+	}
+
+	if !parkedAt.IsZero() {
+		// The resource owner must have logged in after the request was
+		// parked, unless they only had to select an account. Otherwise
+		// the login page sent them back without authenticating them.
+		reauthenticated := session != nil && !session.AuthTime.Before(parkedAt)
+		selectedAccount := session != nil && containsString(prompt, "select_account") && !containsString(prompt, "login")
+		if !reauthenticated && !selectedAccount {
+			redirectWithError(w, req, redirectURI, state, ErrLoginRequired)
+			return nil
+		}
+		if maxAge >= 0 && time.Since(session.AuthTime) > time.Duration(maxAge)*time.Second {
+			redirectWithError(w, req, redirectURI, state, ErrLoginRequired)
+			return nil
+		}
+		return session
+	}
+
+	loginPrompt := ""
+	if containsString(prompt, "login") {
+		loginPrompt = "login"
+	} else if containsString(prompt, "select_account") {
+		loginPrompt = "select_account"
+	}
+
+	needsLogin := session == nil || loginPrompt != ""
+	if session != nil && maxAge >= 0 && time.Since(session.AuthTime) > time.Duration(maxAge)*time.Second {
+		needsLogin = true
+		loginPrompt = "login"
+	}
+	if !needsLogin {
+		return session
+	}
+
+	if containsString(prompt, "none") || h.LoginURL == "" {
+		redirectWithError(w, req, redirectURI, state, ErrLoginRequired)
+		return nil
+	}
+
+	params := make(url.Values, len(req.Form)+1)
+	for k, vs := range req.Form {
+		params[k] = vs
+	}
+	params.Set(parkedAtParam, strconv.FormatInt(time.Now().Unix(), 10))
+
+	id, err := h.RequestStore.Park(req.Context(), params)
+	if err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil
+	}
+
+	u, err := url.Parse(h.LoginURL)
+	if err != nil {
+		h.logger.Println(err)
+		redirectWithError(w, req, redirectURI, state, ErrServerError)
+		return nil
+	}
+	query := u.Query()
+	query.Set("resume", id)
+	if loginPrompt != "" {
+		query.Set("prompt", loginPrompt)
+	}
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, req, u.String(), http.StatusFound)
+	return nil
+}
+
+// removeLoginPrompts removes the prompt values satisfied by a completed
+// login, so that follow-up requests do not ask for login again.
+func removeLoginPrompts(prompt []string) []string {
+	filtered := make([]string, 0, len(prompt))
+	for _, p := range prompt {
+		if p != "login" && p != "select_account" {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
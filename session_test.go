@@ -0,0 +1,122 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testAuthenticator struct {
+	session *Session
+}
+
+func (a *testAuthenticator) Session(req *http.Request) (*Session, error) {
+	return a.session, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	fresh := &Session{Subject: "alice", AuthTime: time.Now()}
+	recent := &Session{Subject: "alice", AuthTime: time.Now().Add(-10 * time.Minute)}
+	stale := &Session{Subject: "alice", AuthTime: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name     string
+		session  *Session
+		query    string
+		parked   time.Duration
+		expected *Session
+		location string
+	}{
+		{"Authenticated", fresh, "", 0, fresh, ""},
+		{"Unauthenticated", nil, "", 0, nil, "https://as.example.com/login?resume="},
+		{"PromptNone", nil, "prompt=none", 0, nil, "error=login_required"},
+		{"PromptNoneAuthenticated", fresh, "prompt=none", 0, fresh, ""},
+		{"PromptNoneCombined", fresh, "prompt=none+login", 0, nil, "error=invalid_request"},
+		{"PromptLogin", fresh, "prompt=login", 0, nil, "prompt=login"},
+		{"PromptSelectAccount", fresh, "prompt=select_account", 0, nil, "prompt=select_account"},
+		{"MaxAge", fresh, "max_age=60", 0, fresh, ""},
+		{"MaxAgeExceeded", stale, "max_age=60", 0, nil, "prompt=login"},
+		{"MaxAgeInvalid", fresh, "max_age=foo", 0, nil, "error=invalid_request"},
+		{"Resumed", fresh, "prompt=login&max_age=60", time.Minute, fresh, ""},
+		{"ResumedUnauthenticated", nil, "", time.Minute, nil, "error=login_required"},
+		{"ResumedPromptLoginWithoutLogin", stale, "prompt=login", time.Minute, nil, "error=login_required"},
+		{"ResumedMaxAgeWithoutLogin", stale, "max_age=60", time.Minute, nil, "error=login_required"},
+		{"ResumedMaxAgeExceeded", recent, "max_age=60", 20 * time.Minute, nil, "error=login_required"},
+		{"ResumedSelectAccount", stale, "prompt=select_account", time.Minute, stale, ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := NewHandler(nil, nil)
+			h.Authenticator = &testAuthenticator{tt.session}
+			h.LoginURL = "https://as.example.com/login"
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/authorize?"+tt.query, nil)
+			req.ParseForm()
+
+			var parkedAt time.Time
+			if tt.parked > 0 {
+				parkedAt = time.Now().Add(-tt.parked)
+			}
+
+			got := h.authenticate(w, req, "https://client.example.com/cb", "xyz", strings.Fields(req.FormValue("prompt")), parkedAt)
+			if got != tt.expected {
+				t.Errorf("authenticate => %v, expected %v", got, tt.expected)
+			}
+			if location := w.Header().Get("Location"); !strings.Contains(location, tt.location) {
+				t.Errorf("authenticate redirected => %s, expected %s", location, tt.location)
+			}
+		})
+	}
+}
+
+func TestMemoryRequestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRequestStore(time.Minute)
+
+	params := url.Values{"response_type": {"token"}}
+	id, err := store.Park(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Resume(ctx, id)
+	if err != nil || got.Get("response_type") != "token" {
+		t.Errorf("Resume(%s) => %v, %v, expected %v", id, got, err, params)
+	}
+
+	got, err = store.Resume(ctx, id)
+	if err != nil || got != nil {
+		t.Errorf("Resume(%s) twice => %v, %v, expected nil", id, got, err)
+	}
+}
+
+func TestResumeRequest(t *testing.T) {
+	h := NewHandler(nil, nil)
+	h.Authenticator = &testAuthenticator{}
+	h.LoginURL = "https://as.example.com/login"
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/authorize?response_type=token&parked_at=1", nil)
+	req.ParseForm()
+	h.authenticate(w, req, "https://client.example.com/cb", "xyz", nil, time.Time{})
+
+	location, _ := url.Parse(w.Header().Get("Location"))
+	req = httptest.NewRequest(http.MethodGet, "/authorize?resume="+location.Query().Get("resume"), nil)
+	parkedAt, err := h.resumeRequest(req)
+	if err != nil || time.Since(parkedAt) > time.Minute {
+		t.Errorf("resumeRequest => %v, %v, expected now", parkedAt, err)
+	}
+	if req.Form.Get("response_type") != "token" || req.Form.Get(parkedAtParam) != "" {
+		t.Errorf("resumeRequest params => %v, expected parked params", req.Form)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+var _ oauth2.MultiSecretClient = (*Client)(nil)
+
+// Client is a client stored in the database. Clients with a secret are
+// confidential, their secret is verified by the SecretVerifier of the
+// handler.
+type Client struct {
+	ID           string
+	SecretHash   string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+}
+
+// Identifier returns the client identifier.
+func (c *Client) Identifier() string {
+	return c.ID
+}
+
+// IsAllowedRedirectURI reports whether the redirection URI matches a
+// registered one.
+func (c *Client) IsAllowedRedirectURI(uri string) bool {
+	return oauth2.DefaultRedirectURIMatcher.Match(c.RedirectURIs, uri)
+}
+
+// RegisteredRedirectURIs returns the registered redirection URIs.
+func (c *Client) RegisteredRedirectURIs() []string {
+	return c.RedirectURIs
+}
+
+// IsAllowedGrantType reports whether the client may use the grant type.
+func (c *Client) IsAllowedGrantType(identifier string) bool {
+	return contains(c.GrantTypes, identifier)
+}
+
+// IsConfidential reports whether the client has a secret.
+func (c *Client) IsConfidential() bool {
+	return c.SecretHash != ""
+}
+
+// Authenticate compares the secret with the stored hash.
+func (c *Client) Authenticate(secret string) bool {
+	return c.IsConfidential() && oauth2.CompareSecret(c.SecretHash, secret)
+}
+
+// ClientSecrets returns the stored hash of a confidential client.
+func (c *Client) ClientSecrets() []oauth2.ClientSecret {
+	if !c.IsConfidential() {
+		return nil
+	}
+	return []oauth2.ClientSecret{{Hash: c.SecretHash}}
+}
+
+// FindClient finds the client by its identifier. It returns nil if the
+// client does not exist.
+func (s *Store) FindClient(ctx context.Context, id string) (oauth2.Client, error) {
+	c, err := s.findClient(ctx, id)
+	if c == nil || err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *Store) findClient(ctx context.Context, id string) (*Client, error) {
+	var redirectURIs, grantTypes, scopes string
+	c := &Client{ID: id}
+
+	row := s.queryRow(ctx, "SELECT secret_hash, redirect_uris, grant_types, scopes FROM oauth2_clients WHERE id = ?", id)
+	err := row.Scan(&c.SecretHash, &redirectURIs, &grantTypes, &scopes)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.RedirectURIs = splitList(redirectURIs)
+	c.GrantTypes = splitList(grantTypes)
+	c.Scopes = splitList(scopes)
+
+	return c, nil
+}
+
+// SaveClient creates or replaces the client. A non-empty secret is
+// hashed into client.SecretHash, otherwise client.SecretHash is stored
+// as is.
+func (s *Store) SaveClient(ctx context.Context, client *Client, secret string) error {
+	secretHash := client.SecretHash
+	if secret != "" {
+		var err error
+		secretHash, err = oauth2.HashSecret(secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := s.exec(ctx, s.dialect.upsertClient,
+		client.ID,
+		secretHash,
+		joinList(client.RedirectURIs),
+		joinList(client.GrantTypes),
+		joinList(client.Scopes),
+	)
+	if err != nil {
+		return err
+	}
+
+	client.SecretHash = secretHash
+	return nil
+}
+
+// DeleteClient deletes the client.
+func (s *Store) DeleteClient(ctx context.Context, id string) error {
+	_, err := s.exec(ctx, "DELETE FROM oauth2_clients WHERE id = ?", id)
+	return err
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
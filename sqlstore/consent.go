@@ -0,0 +1,68 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+// FindConsent returns the consent of the subject for the client, or nil
+// if there is none.
+func (s *Store) FindConsent(ctx context.Context, subject, clientID string) (*oauth2.Consent, error) {
+	consent := &oauth2.Consent{Subject: subject, ClientID: clientID}
+
+	var scopes string
+	row := s.queryRow(ctx, "SELECT scopes, granted_at FROM oauth2_consents WHERE subject = ? AND client_id = ?", subject, clientID)
+	err := row.Scan(&scopes, &consent.GrantedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	consent.Scopes = splitList(scopes)
+	return consent, nil
+}
+
+// SaveConsent creates or replaces the consent of the subject for the
+// client.
+func (s *Store) SaveConsent(ctx context.Context, consent *oauth2.Consent) error {
+	_, err := s.exec(ctx, s.dialect.upsertConsent,
+		consent.Subject,
+		consent.ClientID,
+		joinList(consent.Scopes),
+		consent.GrantedAt.UTC(),
+	)
+	return err
+}
+
+// ListConsents returns all consents of the subject.
+func (s *Store) ListConsents(ctx context.Context, subject string) ([]*oauth2.Consent, error) {
+	rows, err := s.query(ctx, "SELECT client_id, scopes, granted_at FROM oauth2_consents WHERE subject = ? ORDER BY client_id", subject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consents []*oauth2.Consent
+	for rows.Next() {
+		consent := &oauth2.Consent{Subject: subject}
+		var scopes string
+		if err := rows.Scan(&consent.ClientID, &scopes, &consent.GrantedAt); err != nil {
+			return nil, err
+		}
+		consent.Scopes = splitList(scopes)
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
+
+// RevokeConsent deletes the consent of the subject for the client.
+func (s *Store) RevokeConsent(ctx context.Context, subject, clientID string) error {
+	_, err := s.exec(ctx, "DELETE FROM oauth2_consents WHERE subject = ? AND client_id = ?", subject, clientID)
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect holds the database specific SQL.
+type Dialect struct {
+	name             string
+	numbered         bool
+	createMigrations string
+	upsertClient     string
+	upsertToken      string
+	upsertConsent    string
+}
+
+// Postgres is the PostgreSQL dialect.
+var Postgres = &Dialect{
+	name:     "postgres",
+	numbered: true,
+	createMigrations: `CREATE TABLE IF NOT EXISTS oauth2_migrations (
+	version INTEGER PRIMARY KEY
+)`,
+	upsertClient: `INSERT INTO oauth2_clients (id, secret_hash, redirect_uris, grant_types, scopes)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	secret_hash = EXCLUDED.secret_hash,
+	redirect_uris = EXCLUDED.redirect_uris,
+	grant_types = EXCLUDED.grant_types,
+	scopes = EXCLUDED.scopes`,
+	upsertToken: `INSERT INTO oauth2_tokens (token_hash, kind, client_id, subject, scopes, audience, authorization_details, family, retired, issued_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (token_hash) DO UPDATE SET
+	kind = EXCLUDED.kind,
+	client_id = EXCLUDED.client_id,
+	subject = EXCLUDED.subject,
+	scopes = EXCLUDED.scopes,
+	audience = EXCLUDED.audience,
+	authorization_details = EXCLUDED.authorization_details,
+	family = EXCLUDED.family,
+	retired = EXCLUDED.retired,
+	issued_at = EXCLUDED.issued_at,
+	expires_at = EXCLUDED.expires_at`,
+	upsertConsent: `INSERT INTO oauth2_consents (subject, client_id, scopes, granted_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (subject, client_id) DO UPDATE SET
+	scopes = EXCLUDED.scopes,
+	granted_at = EXCLUDED.granted_at`,
+}
+
+// MySQL is the MySQL dialect.
+var MySQL = &Dialect{
+	name: "mysql",
+	createMigrations: `CREATE TABLE IF NOT EXISTS oauth2_migrations (
+	version INTEGER NOT NULL PRIMARY KEY
+)`,
+	upsertClient: `INSERT INTO oauth2_clients (id, secret_hash, redirect_uris, grant_types, scopes)
+VALUES (?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	secret_hash = VALUES(secret_hash),
+	redirect_uris = VALUES(redirect_uris),
+	grant_types = VALUES(grant_types),
+	scopes = VALUES(scopes)`,
+	upsertToken: `INSERT INTO oauth2_tokens (token_hash, kind, client_id, subject, scopes, audience, authorization_details, family, retired, issued_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	kind = VALUES(kind),
+	client_id = VALUES(client_id),
+	subject = VALUES(subject),
+	scopes = VALUES(scopes),
+	audience = VALUES(audience),
+	authorization_details = VALUES(authorization_details),
+	family = VALUES(family),
+	retired = VALUES(retired),
+	issued_at = VALUES(issued_at),
+	expires_at = VALUES(expires_at)`,
+	upsertConsent: `INSERT INTO oauth2_consents (subject, client_id, scopes, granted_at)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	scopes = VALUES(scopes),
+	granted_at = VALUES(granted_at)`,
+}
+
+// rebind replaces the ? placeholders of the query with numbered ones
+// for dialects that require them.
+func (d *Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
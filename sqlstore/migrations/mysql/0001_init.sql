@@ -0,0 +1,31 @@
+CREATE TABLE oauth2_clients (
+	id            VARCHAR(255) NOT NULL PRIMARY KEY,
+	secret_hash   VARCHAR(255) NOT NULL DEFAULT '',
+	redirect_uris TEXT         NOT NULL,
+	grant_types   TEXT         NOT NULL,
+	scopes        TEXT         NOT NULL
+) CHARACTER SET utf8mb4;
+
+CREATE TABLE oauth2_tokens (
+	token_hash            CHAR(64)     NOT NULL PRIMARY KEY,
+	kind                  VARCHAR(32)  NOT NULL,
+	client_id             VARCHAR(255) NOT NULL,
+	subject               VARCHAR(255) NOT NULL DEFAULT '',
+	scopes                TEXT         NOT NULL,
+	audience              TEXT         NOT NULL,
+	authorization_details TEXT         NOT NULL,
+	family                VARCHAR(64)  NOT NULL DEFAULT '',
+	retired               BOOLEAN      NOT NULL DEFAULT FALSE,
+	issued_at             DATETIME(6)  NOT NULL,
+	expires_at            DATETIME(6)  NULL,
+	INDEX oauth2_tokens_family (family),
+	INDEX oauth2_tokens_expires_at (expires_at)
+) CHARACTER SET utf8mb4;
+
+CREATE TABLE oauth2_consents (
+	subject    VARCHAR(255) NOT NULL,
+	client_id  VARCHAR(255) NOT NULL,
+	scopes     TEXT         NOT NULL,
+	granted_at DATETIME(6)  NOT NULL,
+	PRIMARY KEY (subject, client_id)
+) CHARACTER SET utf8mb4;
@@ -0,0 +1,33 @@
+CREATE TABLE oauth2_clients (
+	id            VARCHAR(255) PRIMARY KEY,
+	secret_hash   VARCHAR(255) NOT NULL DEFAULT '',
+	redirect_uris TEXT         NOT NULL DEFAULT '',
+	grant_types   TEXT         NOT NULL DEFAULT '',
+	scopes        TEXT         NOT NULL DEFAULT ''
+);
+
+CREATE TABLE oauth2_tokens (
+	token_hash            CHAR(64)     PRIMARY KEY,
+	kind                  VARCHAR(32)  NOT NULL,
+	client_id             VARCHAR(255) NOT NULL,
+	subject               VARCHAR(255) NOT NULL DEFAULT '',
+	scopes                TEXT         NOT NULL DEFAULT '',
+	audience              TEXT         NOT NULL DEFAULT '',
+	authorization_details TEXT         NOT NULL DEFAULT '',
+	family                VARCHAR(64)  NOT NULL DEFAULT '',
+	retired               BOOLEAN      NOT NULL DEFAULT FALSE,
+	issued_at             TIMESTAMP    NOT NULL,
+	expires_at            TIMESTAMP    NULL
+);
+
+CREATE INDEX oauth2_tokens_family ON oauth2_tokens (family);
+
+CREATE INDEX oauth2_tokens_expires_at ON oauth2_tokens (expires_at);
+
+CREATE TABLE oauth2_consents (
+	subject    VARCHAR(255) NOT NULL,
+	client_id  VARCHAR(255) NOT NULL,
+	scopes     TEXT         NOT NULL DEFAULT '',
+	granted_at TIMESTAMP    NOT NULL,
+	PRIMARY KEY (subject, client_id)
+);
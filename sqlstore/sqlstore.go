@@ -0,0 +1,136 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+// Package sqlstore implements oauth2 storage on top of database/sql.
+//
+// Store implements oauth2.Storer, oauth2.TokenStore,
+// oauth2.RefreshTokenFamilies and oauth2.ConsentStore, and manages
+// clients. It supports PostgreSQL and MySQL; for MySQL the DSN must
+// enable parseTime.
+package sqlstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+var _ oauth2.Storer = (*Store)(nil)
+var _ oauth2.TokenStore = (*Store)(nil)
+var _ oauth2.RefreshTokenFamilies = (*Store)(nil)
+var _ oauth2.ConsentStore = (*Store)(nil)
+
+// Store stores clients, tokens and consents in a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect *Dialect
+}
+
+// New creates a new store on the database using the dialect.
+func New(db *sql.DB, dialect *Dialect) *Store {
+	return &Store{db, dialect}
+}
+
+// Migrate applies all pending schema migrations.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.createMigrations); err != nil {
+		return err
+	}
+
+	var current int
+	row := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM oauth2_migrations")
+	if err := row.Scan(&current); err != nil {
+		return err
+	}
+
+	dir := "migrations/" + s.dialect.name
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var version int
+		if _, err := fmt.Sscanf(name, "%d_", &version); err != nil {
+			return fmt.Errorf("sqlstore: invalid migration name %s", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		script, err := migrations.ReadFile(dir + "/" + name)
+		if err != nil {
+			return err
+		}
+		if err := s.migrate(ctx, version, string(script)); err != nil {
+			return fmt.Errorf("sqlstore: migration %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) migrate(ctx context.Context, version int, script string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range strings.Split(script, ";\n") {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind("INSERT INTO oauth2_migrations (version) VALUES (?)"), version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func joinList(list []string) string {
+	return strings.Join(list, " ")
+}
+
+func splitList(s string) []string {
+	return strings.Fields(s)
+}
+
+// hashToken returns the key tokens are stored under, so that a leaked
+// database does not leak usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
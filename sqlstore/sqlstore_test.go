@@ -0,0 +1,272 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+// fakeHandler answers a statement with result columns and rows, or the
+// number of affected rows.
+type fakeHandler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, affected int64, err error)
+
+type fakeConnector struct {
+	handle fakeHandler
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{c.handle}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+type fakeConn struct {
+	handle fakeHandler
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.handle, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	handle fakeHandler
+	query  string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, _, affected, err := s.handle(s.query, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	columns, rows, _, err := s.handle(s.query, args)
+	return &fakeRows{columns: columns, rows: rows}, err
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	version := int64(0)
+
+	db := sql.OpenDB(&fakeConnector{func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, query)
+		switch {
+		case strings.HasPrefix(query, "SELECT COALESCE(MAX(version), 0)"):
+			return []string{"version"}, [][]driver.Value{{version}}, 0, nil
+		case strings.HasPrefix(query, "INSERT INTO oauth2_migrations"):
+			if query != "INSERT INTO oauth2_migrations (version) VALUES ($1)" {
+				t.Errorf("migration version query => %s, expected numbered placeholder", query)
+			}
+			version = args[0].(int64)
+		}
+		return nil, nil, 1, nil
+	}})
+	defer db.Close()
+
+	store := New(db, Postgres)
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Errorf("Migrate => version %d, expected 1", version)
+	}
+
+	created := 0
+	for _, query := range queries {
+		if strings.HasPrefix(query, "CREATE TABLE oauth2_") {
+			created++
+		}
+	}
+	if created != 3 {
+		t.Errorf("Migrate => created %d tables, expected 3", created)
+	}
+
+	queries = nil
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("Migrate twice => %v, expected no migrations", queries)
+	}
+}
+
+func TestClient(t *testing.T) {
+	var mu sync.Mutex
+	clients := map[string][]driver.Value{}
+
+	db := sql.OpenDB(&fakeConnector{func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO oauth2_clients"):
+			clients[args[0].(string)] = args[1:]
+			return nil, nil, 1, nil
+		case strings.HasPrefix(query, "SELECT secret_hash, redirect_uris, grant_types, scopes FROM oauth2_clients"):
+			row, ok := clients[args[0].(string)]
+			if !ok {
+				return []string{"secret_hash", "redirect_uris", "grant_types", "scopes"}, nil, 0, nil
+			}
+			return []string{"secret_hash", "redirect_uris", "grant_types", "scopes"}, [][]driver.Value{row}, 0, nil
+		}
+		t.Errorf("unexpected query %s", query)
+		return nil, nil, 0, nil
+	}})
+	defer db.Close()
+
+	ctx := context.Background()
+	store := New(db, MySQL)
+
+	err := store.SaveClient(ctx, &Client{
+		ID:           "foo",
+		RedirectURIs: []string{"https://client.example.com/cb"},
+		GrantTypes:   []string{oauth2.ImplicitGrantType, oauth2.RefreshGrantType},
+	}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := clients["foo"][0].(string); stored == "secret" || !strings.HasPrefix(stored, "pbkdf2-sha256$") {
+		t.Errorf("SaveClient stored secret %s, expected a hash", stored)
+	}
+
+	client, err := store.FindClient(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.IsConfidential() || !client.Authenticate("secret") || client.Authenticate("wrong") {
+		t.Errorf("FindClient(foo) => %+v, expected confidential client authenticating with its secret", client)
+	}
+	if msc, ok := client.(oauth2.MultiSecretClient); !ok || oauth2.DefaultSecretVerifier.Verify(msc.ClientSecrets(), "secret", time.Now()) == nil {
+		t.Errorf("FindClient(foo) => %+v, expected secret verified by the SecretVerifier", client)
+	}
+	if !client.IsAllowedRedirectURI("https://client.example.com/cb") || !client.IsAllowedGrantType(oauth2.RefreshGrantType) || client.IsAllowedGrantType(oauth2.PasswordGrantType) {
+		t.Errorf("FindClient(foo) => %+v, expected stored redirect URIs and grant types", client)
+	}
+
+	client, err = store.FindClient(ctx, "bar")
+	if client != nil || err != nil {
+		t.Errorf("FindClient(bar) => %v, %v, expected nil", client, err)
+	}
+}
+
+func TestFindToken(t *testing.T) {
+	now := time.Now()
+	columns := []string{"kind", "client_id", "subject", "scopes", "audience", "authorization_details", "family", "retired", "issued_at", "expires_at"}
+	tokens := map[string][]driver.Value{
+		hashToken("access"):  {oauth2.AccessTokenKind, "foo", "alice", "read write", "https://api.example.com/", `[{"type":"payment_initiation"}]`, "", false, now, now.Add(time.Hour)},
+		hashToken("expired"): {oauth2.AccessTokenKind, "foo", "alice", "", "", "", "", false, now.Add(-time.Hour), now.Add(-time.Second)},
+		hashToken("refresh"): {oauth2.RefreshTokenKind, "foo", "alice", "", "", "", "family", true, now, nil},
+	}
+
+	db := sql.OpenDB(&fakeConnector{func(query string, args []driver.Value) ([]string, [][]driver.Value, int64, error) {
+		row, ok := tokens[args[0].(string)]
+		if !ok {
+			return columns, nil, 0, nil
+		}
+		return columns, [][]driver.Value{row}, 0, nil
+	}})
+	defer db.Close()
+
+	ctx := context.Background()
+	store := New(db, Postgres)
+
+	token, err := store.FindToken(ctx, "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Value != "access" || token.Subject != "alice" || len(token.Scopes) != 2 || len(token.AuthorizationDetails) != 1 || !token.IsActive(now) {
+		t.Errorf("FindToken(access) => %+v", token)
+	}
+
+	for _, value := range []string{"expired", "unknown"} {
+		if token, err := store.FindToken(ctx, value); token != nil || err != nil {
+			t.Errorf("FindToken(%s) => %v, %v, expected nil", value, token, err)
+		}
+	}
+
+	family, clientID, active, err := store.FindRefreshToken(ctx, "refresh")
+	if family != "family" || clientID != "foo" || active || err != nil {
+		t.Errorf("FindRefreshToken(refresh) => %s, %s, %t, %v, expected retired token of foo in family", family, clientID, active, err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT a FROM b WHERE c = ? AND d = ?"
+
+	if got := MySQL.rebind(query); got != query {
+		t.Errorf("MySQL.rebind => %s, expected %s", got, query)
+	}
+
+	expected := "SELECT a FROM b WHERE c = $1 AND d = $2"
+	if got := Postgres.rebind(query); got != expected {
+		t.Errorf("Postgres.rebind => %s, expected %s", got, expected)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/danilobuerger/oauth2"
+)
+
+// SaveToken creates or replaces the token.
+func (s *Store) SaveToken(ctx context.Context, token *oauth2.Token) error {
+	details := ""
+	if len(token.AuthorizationDetails) > 0 {
+		data, err := json.Marshal(token.AuthorizationDetails)
+		if err != nil {
+			return err
+		}
+		details = string(data)
+	}
+
+	var expiresAt sql.NullTime
+	if !token.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: token.ExpiresAt.UTC(), Valid: true}
+	}
+
+	_, err := s.exec(ctx, s.dialect.upsertToken,
+		hashToken(token.Value),
+		token.Kind,
+		token.ClientID,
+		token.Subject,
+		joinList(token.Scopes),
+		joinList(token.Audience),
+		details,
+		token.Family,
+		token.Retired,
+		token.IssuedAt.UTC(),
+		expiresAt,
+	)
+	return err
+}
+
+// FindToken returns the token, or nil if it is unknown or expired.
+// Retired refresh tokens are returned until they expire.
+func (s *Store) FindToken(ctx context.Context, value string) (*oauth2.Token, error) {
+	token := &oauth2.Token{Value: value}
+
+	var scopes, audience, details string
+	var expiresAt sql.NullTime
+
+	row := s.queryRow(ctx, `SELECT kind, client_id, subject, scopes, audience, authorization_details, family, retired, issued_at, expires_at
+FROM oauth2_tokens WHERE token_hash = ?`, hashToken(value))
+	err := row.Scan(&token.Kind, &token.ClientID, &token.Subject, &scopes, &audience, &details, &token.Family, &token.Retired, &token.IssuedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = expiresAt.Time
+		if !time.Now().Before(token.ExpiresAt) {
+			return nil, nil
+		}
+	}
+
+	token.Scopes = splitList(scopes)
+	token.Audience = splitList(audience)
+	if details != "" {
+		if err := json.Unmarshal([]byte(details), &token.AuthorizationDetails); err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// RevokeToken deletes the token.
+func (s *Store) RevokeToken(ctx context.Context, value string) error {
+	_, err := s.exec(ctx, "DELETE FROM oauth2_tokens WHERE token_hash = ?", hashToken(value))
+	return err
+}
+
+// RevokeFamily deletes all tokens of the family.
+func (s *Store) RevokeFamily(ctx context.Context, family string) error {
+	_, err := s.exec(ctx, "DELETE FROM oauth2_tokens WHERE family = ?", family)
+	return err
+}
+
+// DeleteExpired deletes all expired tokens.
+func (s *Store) DeleteExpired(ctx context.Context) error {
+	_, err := s.exec(ctx, "DELETE FROM oauth2_tokens WHERE expires_at <= ?", time.Now().UTC())
+	return err
+}
+
+// StartRefreshTokenFamily starts a new family with the refresh token.
+// The refresh token must have been saved before.
+func (s *Store) StartRefreshTokenFamily(ctx context.Context, clientID, token string) error {
+	key := hashToken(token)
+	result, err := s.exec(ctx, "UPDATE oauth2_tokens SET family = ? WHERE token_hash = ? AND client_id = ?", key, key, clientID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// FindRefreshToken returns the family of the refresh token, the client
+// it was issued to and whether it is the active one.
+func (s *Store) FindRefreshToken(ctx context.Context, token string) (string, string, bool, error) {
+	t, err := s.FindToken(ctx, token)
+	if t == nil || err != nil || t.Kind != oauth2.RefreshTokenKind {
+		return "", "", false, err
+	}
+	return t.Family, t.ClientID, !t.Retired, nil
+}
+
+// RetireRefreshToken retires the active refresh token of the family.
+func (s *Store) RetireRefreshToken(ctx context.Context, family, token string) error {
+	result, err := s.exec(ctx, "UPDATE oauth2_tokens SET retired = ? WHERE token_hash = ? AND family = ? AND retired = ?", true, hashToken(token), family, false)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return oauth2.ErrInvalidGrant
+	}
+	return nil
+}
+
+// AddRefreshToken makes the new refresh token the active refresh token
+// of the family. The new refresh token inherits the client, subject,
+// scopes and audience of the old one unless it has been saved before.
+func (s *Store) AddRefreshToken(ctx context.Context, family, oldToken, newToken string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldKey, newKey := hashToken(oldToken), hashToken(newToken)
+
+	result, err := tx.ExecContext(ctx, s.dialect.rebind("UPDATE oauth2_tokens SET family = ? WHERE token_hash = ?"), family, newKey)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		result, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO oauth2_tokens (token_hash, kind, client_id, subject, scopes, audience, authorization_details, family, retired, issued_at, expires_at)
+SELECT ?, kind, client_id, subject, scopes, audience, authorization_details, family, ?, ?, expires_at
+FROM oauth2_tokens WHERE token_hash = ? AND family = ?`), newKey, false, time.Now().UTC(), oldKey, family)
+		if err != nil {
+			return err
+		}
+		if err := requireRow(result); err != nil {
+			return oauth2.ErrInvalidGrant
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes all tokens of the family.
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	return s.RevokeFamily(ctx, family)
+}
+
+func requireRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Kinds of tokens kept in a TokenStore.
+const (
+	AccessTokenKind       = "access_token"
+	RefreshTokenKind      = "refresh_token"
+	AuthorizationCodeKind = "authorization_code"
+	DeviceCodeKind        = "device_code"
+)
+
+// Token is an issued access token, refresh token, authorization code or
+// device code.
+type Token struct {
+	Value     string
+	Kind      string
+	ClientID  string
+	Subject   string
+	Scopes    []string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// AuthorizationDetails holds the granted authorization details, if any.
+	AuthorizationDetails []AuthorizationDetail
+
+	// Family groups the tokens issued from the same grant, e.g. rotated
+	// refresh tokens and the access tokens issued with them. It is an
+	// opaque identifier, never a token value, as it appears in security
+	// events and logs.
+	Family string
+	// Retired marks a rotated refresh token, kept until it expires to
+	// detect its reuse.
+	Retired bool
+}
+
+// IsActive returns whether the token can be used at the given time.
+func (t *Token) IsActive(now time.Time) bool {
+	return !t.Retired && (t.ExpiresAt.IsZero() || now.Before(t.ExpiresAt))
+}
+
+// TokenStore stores issued tokens. Grant type services save the tokens
+// they issue, the handler uses them for revocation and introspection and
+// rejects refresh tokens it does not know.
+type TokenStore interface {
+	// SaveToken creates or replaces the token.
+	SaveToken(ctx context.Context, token *Token) error
+	// FindToken returns the token, or nil if it is unknown or expired.
+	FindToken(ctx context.Context, value string) (*Token, error)
+	// RevokeToken deletes the token.
+	RevokeToken(ctx context.Context, value string) error
+	// RevokeFamily deletes all tokens of the family.
+	RevokeFamily(ctx context.Context, family string) error
+}
+
+// NewMemoryTokenStore creates a new thread-safe token store holding
+// tokens in memory. Expired tokens are removed by Sweep.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]*Token),
+	}
+}
+
+var _ TokenStore = (*MemoryTokenStore)(nil)
+var _ RefreshTokenFamilies = (*MemoryTokenStore)(nil)
+
+// MemoryTokenStore is an in-memory TokenStore. It also tracks refresh
+// token families for NewRotatingRefreshGrantType.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*Token
+}
+
+// SaveToken creates or replaces the token.
+func (s *MemoryTokenStore) SaveToken(ctx context.Context, token *Token) error {
+	t := *token
+
+	s.mu.Lock()
+	s.tokens[t.Value] = &t
+	s.mu.Unlock()
+
+	return nil
+}
+
+// FindToken returns the token, or nil if it is unknown or expired.
+// Retired refresh tokens are returned until they expire.
+func (s *MemoryTokenStore) FindToken(ctx context.Context, value string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.tokens[value]
+	if !ok || isExpired(t, time.Now()) {
+		return nil, nil
+	}
+
+	token := *t
+	return &token, nil
+}
+
+// RevokeToken deletes the token.
+func (s *MemoryTokenStore) RevokeToken(ctx context.Context, value string) error {
+	s.mu.Lock()
+	delete(s.tokens, value)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeFamily deletes all tokens of the family.
+func (s *MemoryTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for value, t := range s.tokens {
+		if t.Family == family {
+			delete(s.tokens, value)
+		}
+	}
+
+	return nil
+}
+
+// StartRefreshTokenFamily starts a new family with the refresh token.
+// The family is identified by the SHA-256 hash of the refresh token.
+func (s *MemoryTokenStore) StartRefreshTokenFamily(ctx context.Context, clientID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok {
+		t = &Token{
+			Value:    token,
+			Kind:     RefreshTokenKind,
+			ClientID: clientID,
+			IssuedAt: time.Now(),
+		}
+		s.tokens[token] = t
+	}
+	t.Family = familyID(token)
+
+	return nil
+}
+
+// FindRefreshToken returns the family of the refresh token, the client
+// it was issued to and whether it is the active one.
+func (s *MemoryTokenStore) FindRefreshToken(ctx context.Context, token string) (string, string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.tokens[token]
+	if !ok || t.Kind != RefreshTokenKind || isExpired(t, time.Now()) {
+		return "", "", false, nil
+	}
+
+	return t.Family, t.ClientID, !t.Retired, nil
+}
+
+// RetireRefreshToken retires the active refresh token of the family.
+func (s *MemoryTokenStore) RetireRefreshToken(ctx context.Context, family, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok || t.Family != family || t.Retired {
+		return ErrInvalidGrant
+	}
+	t.Retired = true
+
+	return nil
+}
+
+// AddRefreshToken makes the new refresh token the active refresh token
+// of the family. The new refresh token inherits the client, subject,
+// scopes and audience of the old one unless it has been saved before.
+func (s *MemoryTokenStore) AddRefreshToken(ctx context.Context, family, oldToken, newToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.tokens[oldToken]
+	if !ok || old.Family != family {
+		return ErrInvalidGrant
+	}
+
+	t, ok := s.tokens[newToken]
+	if !ok {
+		t = &Token{
+			Value:     newToken,
+			Kind:      RefreshTokenKind,
+			ClientID:  old.ClientID,
+			Subject:   old.Subject,
+			Scopes:    old.Scopes,
+			Audience:  old.Audience,
+			IssuedAt:  time.Now(),
+			ExpiresAt: old.ExpiresAt,
+		}
+		s.tokens[newToken] = t
+	}
+	t.Family = family
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily revokes all tokens of the family.
+func (s *MemoryTokenStore) RevokeRefreshTokenFamily(ctx context.Context, family string) error {
+	return s.RevokeFamily(ctx, family)
+}
+
+// Sweep removes expired tokens every interval until the context is
+// done. It is typically run in its own goroutine.
+func (s *MemoryTokenStore) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.deleteExpired(now)
+		}
+	}
+}
+
+func (s *MemoryTokenStore) deleteExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for value, t := range s.tokens {
+		if isExpired(t, now) {
+			delete(s.tokens, value)
+		}
+	}
+}
+
+func isExpired(t *Token, now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// familyID returns the identifier of the family started with the token.
+func familyID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
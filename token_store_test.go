@@ -0,0 +1,80 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+	now := time.Now()
+	family := familyID("refresh")
+
+	store.SaveToken(ctx, &Token{Value: "access", Kind: AccessTokenKind, ClientID: "foo", ExpiresAt: now.Add(time.Hour), Family: family})
+	store.SaveToken(ctx, &Token{Value: "expired", Kind: AccessTokenKind, ClientID: "foo", ExpiresAt: now.Add(-time.Second)})
+	store.SaveToken(ctx, &Token{Value: "refresh", Kind: RefreshTokenKind, ClientID: "foo", Subject: "alice"})
+	store.StartRefreshTokenFamily(ctx, "foo", "refresh")
+
+	if token, _ := store.FindToken(ctx, "access"); token == nil || !token.IsActive(now) {
+		t.Errorf("FindToken(access) => %v, expected active token", token)
+	}
+	if token, _ := store.FindToken(ctx, "expired"); token != nil {
+		t.Errorf("FindToken(expired) => %v, expected nil", token)
+	}
+
+	if err := store.RetireRefreshToken(ctx, family, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RetireRefreshToken(ctx, family, "refresh"); err != ErrInvalidGrant {
+		t.Errorf("RetireRefreshToken(retired) => %v, expected %v", err, ErrInvalidGrant)
+	}
+	if err := store.AddRefreshToken(ctx, family, "refresh", "refresh2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, clientID, active, _ := store.FindRefreshToken(ctx, "refresh"); got != family || clientID != "foo" || active {
+		t.Errorf("FindRefreshToken(refresh) => %s, %s, %t, expected retired token of foo", got, clientID, active)
+	}
+	if token, _ := store.FindToken(ctx, "refresh2"); token == nil || token.Subject != "alice" || token.Family != family {
+		t.Errorf("FindToken(refresh2) => %v, expected rotated token of alice", token)
+	}
+
+	store.RevokeRefreshTokenFamily(ctx, family)
+	for _, value := range []string{"access", "refresh", "refresh2"} {
+		if token, _ := store.FindToken(ctx, value); token != nil {
+			t.Errorf("FindToken(%s) after family revocation => %v, expected nil", value, token)
+		}
+	}
+}
+
+func TestMemoryTokenStoreSweep(t *testing.T) {
+	store := NewMemoryTokenStore()
+	store.SaveToken(context.Background(), &Token{Value: "foo", ExpiresAt: time.Now().Add(5 * time.Millisecond)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Sweep(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.RLock()
+		n := len(store.tokens)
+		store.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Sweep did not remove the expired token")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+)
+
+// The span attribute keys set by the handler.
+const (
+	AttributeEndpoint     = "oauth2.endpoint"
+	AttributeClientID     = "oauth2.client_id"
+	AttributeGrantType    = "oauth2.grant_type"
+	AttributeResponseMode = "oauth2.response_mode"
+	AttributeError        = "oauth2.error"
+)
+
+// SpanAttribute is an attribute of a span.
+type SpanAttribute struct {
+	Key   string
+	Value string
+}
+
+// Tracer starts spans. The handler opens spans around client lookup,
+// client authentication, the grant types and response writing. The
+// returned context carries the span and is passed on in the request
+// context, so spans of services become its children.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span)
+}
+
+// Span is a traced operation.
+type Span interface {
+	SetAttributes(attrs ...SpanAttribute)
+	RecordError(err error)
+	End()
+}
+
+// TracerFunc adapts a function to a Tracer. For OpenTelemetry, wrap
+// trace.Tracer.Start and a trace.Span:
+//
+//	tracer := otel.Tracer("oauth2")
+//	h.Tracer = oauth2.TracerFunc(func(ctx context.Context, name string, attrs ...oauth2.SpanAttribute) (context.Context, oauth2.Span) {
+//		ctx, span := tracer.Start(ctx, name)
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	})
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttributes(attrs ...oauth2.SpanAttribute) {
+//		for _, attr := range attrs {
+//			s.Span.SetAttributes(attribute.String(attr.Key, attr.Value))
+//		}
+//	}
+//
+//	func (s otelSpan) RecordError(err error) {
+//		s.Span.RecordError(err)
+//		s.Span.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	func (s otelSpan) End() { s.Span.End() }
+type TracerFunc func(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span)
+
+// Start starts a span.
+func (f TracerFunc) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return f(ctx, name, attrs...)
+}
+
+// NoopTracer is a Tracer that does not record spans. It is used if the
+// handler has no Tracer.
+var NoopTracer Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...SpanAttribute) {}
+func (noopSpan) RecordError(err error)                {}
+func (noopSpan) End()                                 {}
+
+func (h *Handler) tracer() Tracer {
+	if h.Tracer == nil {
+		return NoopTracer
+	}
+	return h.Tracer
+}
+
+// startSpan starts a span with the Tracer of the handler.
+func (h *Handler) startSpan(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	return h.tracer().Start(ctx, name, attrs...)
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.SetAttributes(SpanAttribute{AttributeError, err.Error()})
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...SpanAttribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *testSpan) End() {
+	s.ended = true
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...SpanAttribute) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]string{}}
+	if parent, ok := ctx.Value(testSpanKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	span.SetAttributes(attrs...)
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func TestTokenTracing(t *testing.T) {
+	storer := testStorer{"foo": &testClient{id: "foo", secret: "bar", grantTypes: []string{PasswordGrantType}}}
+
+	tracer := &testTracer{}
+	h := NewHandler(storer, nil, NewPasswordGrantType(nil, testPasswordService{}))
+	h.Tracer = tracer
+
+	form := url.Values{"grant_type": {"password"}, "username": {"alice"}, "password": {"wrong"}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("foo", "bar")
+	h.Token(httptest.NewRecorder(), req)
+
+	expected := []struct {
+		name, parent string
+		err          error
+	}{
+		{"oauth2.AuthenticateClient", "", nil},
+		{"oauth2.FindClient", "oauth2.AuthenticateClient", nil},
+		{"oauth2.Grant", "", ErrInvalidGrant},
+	}
+
+	if len(tracer.spans) != len(expected) {
+		t.Fatalf("Token => %d spans, expected %d", len(tracer.spans), len(expected))
+	}
+	for i, span := range tracer.spans {
+		if span.name != expected[i].name || span.parent != expected[i].parent || span.err != expected[i].err || !span.ended {
+			t.Errorf("span %d => %+v, expected %+v", i, span, expected[i])
+		}
+	}
+	if attr := tracer.spans[2].attrs[AttributeGrantType]; attr != PasswordGrantType {
+		t.Errorf("Grant span grant type => %s, expected %s", attr, PasswordGrantType)
+	}
+}
+
+type testDeniedImplicitService struct{}
+
+func (testDeniedImplicitService) ImplicitGrantTypeResponse(w http.ResponseWriter, req *http.Request, client Client, params url.Values) (*AccessResponse, error) {
+	return nil, ErrAccessDenied
+}
+
+func TestAuthorizeTracing(t *testing.T) {
+	storer := testStorer{"foo": &testClient{
+		id:           "foo",
+		redirectURIs: []string{"https://client.example.com/cb"},
+		grantTypes:   []string{ImplicitGrantType},
+	}}
+
+	tests := []struct {
+		service ImplicitGrantTypeService
+		err     error
+	}{
+		{testImplicitService{}, nil},
+		{testDeniedImplicitService{}, ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		tracer := &testTracer{}
+		h := NewHandler(storer, nil, NewImplicitGrantType(nil, tt.service))
+		h.Tracer = tracer
+
+		h.Authorize(httptest.NewRecorder(), httptest.NewRequest("GET", "/authorize?response_type=token&client_id=foo&state=xyz&redirect_uri=https://client.example.com/cb", nil))
+
+		expected := []struct {
+			name, parent string
+			err          error
+		}{
+			{"oauth2.AuthenticateClient", "", nil},
+			{"oauth2.FindClient", "oauth2.AuthenticateClient", nil},
+			{"oauth2.Respond", "", tt.err},
+			{"oauth2.WriteResponse", "oauth2.Respond", nil},
+		}
+
+		if len(tracer.spans) != len(expected) {
+			t.Fatalf("Authorize(%T) => %d spans, expected %d", tt.service, len(tracer.spans), len(expected))
+		}
+		for i, span := range tracer.spans {
+			if span.name != expected[i].name || span.parent != expected[i].parent || span.err != expected[i].err || !span.ended {
+				t.Errorf("Authorize(%T) span %d => %+v, expected %+v", tt.service, i, span, expected[i])
+			}
+		}
+		if attr := tracer.spans[3].attrs[AttributeEndpoint]; attr != "authorize" {
+			t.Errorf("Authorize(%T) WriteResponse span endpoint => %s, expected authorize", tt.service, attr)
+		}
+	}
+}
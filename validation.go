@@ -0,0 +1,106 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// DefaultMaxRequestBodySize is the default size limit of request bodies
+// with strict request validation.
+const DefaultMaxRequestBodySize = 64 << 10
+
+// repeatableParams may be included more than once.
+//
+// https://tools.ietf.org/html/rfc8707#section-2
+var repeatableParams = map[string]bool{
+	"resource": true,
+}
+
+// validateTokenRequest strictly validates a token request. Token
+// requests must be POST requests with the parameters in a form encoded
+// body. It writes an error response and returns false if the request is
+// invalid.
+//
+// https://tools.ietf.org/html/rfc6749#section-3.2
+func (h *Handler) validateTokenRequest(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.refuse(w, req, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+		return false
+	}
+
+	if req.URL.RawQuery != "" {
+		h.refuse(w, req, http.StatusBadRequest, NewError(ErrInvalidRequest, "Parameters must be sent in the request body."), "")
+		return false
+	}
+
+	return h.validateForm(w, req)
+}
+
+// validateAuthorizeRequest strictly validates an authorization request.
+// Authorization requests must be GET or POST requests.
+//
+// https://tools.ietf.org/html/rfc6749#section-3.1
+func (h *Handler) validateAuthorizeRequest(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		h.refuse(w, req, http.StatusMethodNotAllowed, ErrInvalidRequest, "")
+		return false
+	}
+
+	if req.Method == http.MethodGet {
+		return h.validateParams(w, req)
+	}
+	return h.validateForm(w, req)
+}
+
+// validateForm validates the content type and size of a form encoded
+// request body, and the parameters of the request.
+func (h *Handler) validateForm(w http.ResponseWriter, req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		h.refuse(w, req, http.StatusBadRequest, NewError(ErrInvalidRequest, "The request body must be application/x-www-form-urlencoded."), "")
+		return false
+	}
+
+	limit := h.MaxRequestBodySize
+	if limit <= 0 {
+		limit = DefaultMaxRequestBodySize
+	}
+	req.Body = http.MaxBytesReader(w, req.Body, limit)
+
+	if err := req.ParseForm(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.refuse(w, req, http.StatusRequestEntityTooLarge, NewError(ErrInvalidRequest, "The request body is too large."), "")
+			return false
+		}
+		h.refuse(w, req, http.StatusBadRequest, ErrInvalidRequest, "")
+		return false
+	}
+
+	return h.validateParams(w, req)
+}
+
+// validateParams rejects requests including a parameter more than once,
+// either in the query or body, or in both.
+//
+// https://tools.ietf.org/html/rfc6749#section-3.1
+func (h *Handler) validateParams(w http.ResponseWriter, req *http.Request) bool {
+	if err := req.ParseForm(); err != nil {
+		h.refuse(w, req, http.StatusBadRequest, ErrInvalidRequest, "")
+		return false
+	}
+
+	for name, values := range req.Form {
+		if len(values) > 1 && !repeatableParams[name] {
+			h.refuse(w, req, http.StatusBadRequest, NewError(ErrInvalidRequest, "Parameters must not be included more than once."), "")
+			return false
+		}
+	}
+
+	return true
+}
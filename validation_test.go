@@ -0,0 +1,66 @@
+// Copyright (c) 2016 Danilo Bürger <info@danilobuerger.de>
+
+package oauth2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	const form = "application/x-www-form-urlencoded"
+
+	tests := []struct {
+		authorize   bool
+		method      string
+		target      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{false, "POST", "/token", form, "grant_type=password", http.StatusOK},
+		{false, "POST", "/token", form + "; charset=utf-8", "grant_type=password&resource=a&resource=b", http.StatusOK},
+		{false, "GET", "/token?grant_type=password", "", "", http.StatusMethodNotAllowed},
+		{false, "POST", "/token?grant_type=password", form, "", http.StatusBadRequest},
+		{false, "POST", "/token", "application/json", `{"grant_type":"password"}`, http.StatusBadRequest},
+		{false, "POST", "/token", form, "grant_type=password&grant_type=client_credentials", http.StatusBadRequest},
+		{false, "POST", "/token", form, "scope=" + strings.Repeat("a", DefaultMaxRequestBodySize), http.StatusRequestEntityTooLarge},
+		{true, "GET", "/authorize?response_type=token", "", "", http.StatusOK},
+		{true, "GET", "/authorize?response_type=token&response_type=code", "", "", http.StatusBadRequest},
+		{true, "POST", "/authorize?response_type=token", form, "response_type=token", http.StatusBadRequest},
+		{true, "POST", "/authorize", form, "response_type=token", http.StatusOK},
+		{true, "PUT", "/authorize", form, "response_type=token", http.StatusMethodNotAllowed},
+	}
+
+	h := NewHandler(nil, nil)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			var ok bool
+			if tt.authorize {
+				ok = h.validateAuthorizeRequest(w, req)
+			} else {
+				ok = h.validateTokenRequest(w, req)
+			}
+
+			status := http.StatusOK
+			if !ok {
+				status = w.Code
+			}
+			if status != tt.expected {
+				t.Errorf("validate(%s %s %s) => %d %s, expected %d", tt.method, tt.target, tt.body, status, w.Body.String(), tt.expected)
+			}
+		})
+	}
+}
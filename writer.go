@@ -4,21 +4,39 @@ package oauth2
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
 func writeError(w http.ResponseWriter, logger Log, status int, err error, state string) {
-	if isServerError(status) {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Status != 0 {
+			status = e.Status
+		}
+		for k := range e.Header {
+			w.Header()[k] = e.Header[k]
+		}
+		if e.Cause != nil {
+			logger.Println(e.Cause)
+		}
+	} else if isServerError(status) {
 		logger.Println(err)
 	}
 
 	resp := struct {
-		Error string `json:"error"`
-		State string `json:"state,omitempty"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+		ErrorURI         string `json:"error_uri,omitempty"`
+		State            string `json:"state,omitempty"`
 	}{
 		Error: errorText(status, err),
 		State: state,
 	}
+	if e != nil {
+		resp.ErrorDescription = e.Description
+		resp.ErrorURI = e.URI
+	}
 
 	data, mErr := json.Marshal(resp)
 	if mErr != nil {
@@ -57,7 +75,14 @@ func writeData(w http.ResponseWriter, status int, data []byte, headers map[strin
 	w.Write(data)
 }
 
+// errorText returns the error code sent to the client. Server errors
+// are hidden unless they are an Error.
 func errorText(status int, err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
 	if isServerError(status) {
 		return http.StatusText(status)
 	}
@@ -5,7 +5,11 @@ package oauth2
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -139,3 +143,47 @@ func TestIsServerError(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteErrorDetails(t *testing.T) {
+	cause := errors.New("code expired in database")
+	err := &Error{
+		Code:        ErrInvalidGrant.Error(),
+		Description: "The authorization code has expired.",
+		URI:         "https://example.com/errors/expired",
+		Header:      http.Header{"X-Foo": {"bar"}},
+		Cause:       cause,
+	}
+
+	if !errors.Is(err, ErrInvalidGrant) || errors.Is(err, ErrInvalidClient) || errors.Is(err, cause) || errors.Is(err, errors.New("invalid_grant")) {
+		t.Errorf("errors.Is(%v) => unexpected result", err)
+	}
+	if errors.Is(&Error{Code: ErrInvalidGrant.Error(), Cause: ErrServerError}, ErrServerError) {
+		t.Errorf("errors.Is(%v) => matched cause, expected only code", err)
+	}
+
+	tests := []struct {
+		err            error
+		status         int
+		expectedStatus int
+		expected       string
+	}{
+		{err, http.StatusBadRequest, http.StatusBadRequest, `{"error":"invalid_grant","error_description":"The authorization code has expired.","error_uri":"https://example.com/errors/expired","state":"xyz"}`},
+		{&Error{Code: "temporarily_unavailable", Status: http.StatusServiceUnavailable}, http.StatusBadRequest, http.StatusServiceUnavailable, `{"error":"temporarily_unavailable","state":"xyz"}`},
+		{fmt.Errorf("database down"), http.StatusInternalServerError, http.StatusInternalServerError, `{"error":"Internal Server Error","state":"xyz"}`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeError(w, log.New(ioutil.Discard, "", 0), tt.status, tt.err, "xyz")
+
+		if w.Code != tt.expectedStatus || w.Body.String() != tt.expected {
+			t.Errorf("writeError(%v) => %d %s, expected %d %s", tt.err, w.Code, w.Body.String(), tt.expectedStatus, tt.expected)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, log.New(ioutil.Discard, "", 0), http.StatusBadRequest, err, "")
+	if w.Header().Get("X-Foo") != "bar" || strings.Contains(w.Body.String(), cause.Error()) {
+		t.Errorf("writeError(%v) => %v %s, expected header and hidden cause", err, w.Header(), w.Body.String())
+	}
+}